@@ -18,12 +18,29 @@ import (
 	"github.com/rs/zerolog/hlog"
 )
 
-// New instantiates a new Bookmarks API instance
-func New(logger zerolog.Logger, store *storage.Store, username, password string) *API {
+// apiTimeout is the time limit of requests that do not fetch remote content
+const apiTimeout = 5 * time.Second
+
+// New instantiates a new Bookmarks API instance, the outbound feeds are secured
+// with tokens derived from feedSecret or, if that is empty, from password
+func New(logger zerolog.Logger, store *storage.Store, username, password, feedSecret string) *API {
+	if feedSecret == "" {
+		feedSecret = password
+	}
+
+	// Routes like refreshing a feed fetch remote content, so they get the fetch timeout on top of the default
+	timeout := apiTimeout
+	if fetchTimeout := storage.DefaultFetcher.Timeout(); fetchTimeout > 0 {
+		timeout += fetchTimeout
+	}
+
+	syndication := syndication{store, feedSecret}
+	events := events{store}
+	thoughts := thoughts{store}
+
 	r := chi.NewRouter()
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Timeout(5 * time.Second))
 	r.Use(middleware.Heartbeat("/ping"))
 
 	r.Route("/api", func(r chi.Router) {
@@ -48,12 +65,35 @@ func New(logger zerolog.Logger, store *storage.Store, username, password string)
 			})
 		})
 
-		r.Mount("/bookmarks", bookmarks{store}.Routes())
-		r.Mount("/feeds", feeds{store}.Routes())
-		r.Mount("/thoughts", thoughts{store}.Routes())
+		// The event stream stays open and uploads can take long, so these are
+		// the only routes without a timeout
+		r.Get("/events", events.stream)
+		r.With(thoughts.middleware).Post("/thoughts/{id}/attachments", thoughts.upload)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(timeout))
+			r.Mount("/bookmarks", bookmarks{store}.Routes())
+			r.Mount("/feeds", feeds{store}.Routes())
+			r.Mount("/hosts", hosts{storage.DefaultFetcher}.Routes())
+			r.Mount("/images", images{store}.Routes())
+			r.Mount("/reminders", reminders{store}.Routes())
+			r.Mount("/rules", rules{store}.Routes())
+			r.Mount("/tasks", tasks{store}.Routes())
+			r.Mount("/thoughts", thoughts.Routes())
+			r.Mount("/webhooks", webhooks{store}.Routes())
+			r.Get("/syndication", syndication.list)
+		})
 	})
 
-	r.Get("/*", webAssetHandler)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(apiTimeout))
+		r.Use(hlog.NewHandler(logger))
+		r.Use(hlog.RemoteAddrHandler("ip"))
+		r.Mount("/feeds", syndication.Routes())
+		r.Mount("/websub", websub{store}.Routes())
+	})
+
+	r.With(middleware.Timeout(apiTimeout)).Get("/*", webAssetHandler)
 
 	return &API{r}
 }
@@ -105,6 +145,56 @@ func webAssetHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(asset)
 }
 
+// negotiate returns the offered media type the client prefers according to
+// the Accept header of the request, or the first offer if none is acceptable
+func negotiate(r *http.Request, offers ...string) string {
+	best, bestQuality := offers[0], 0.0
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+
+		for _, offer := range offers {
+			if quality > bestQuality && (mediaType == offer || mediaType == strings.SplitN(offer, "/", 2)[0]+"/*" || mediaType == "*/*") {
+				best, bestQuality = offer, quality
+				break
+			}
+		}
+	}
+
+	return best
+}
+
+// etag formats the version of a thought or bookmark as an entity tag
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatch checks the If-Match header of a request that changes an existing
+// resource, it returns 428 if the header is missing, 412 if it does not match
+// the version and 0 otherwise
+func ifMatch(r *http.Request, version int) int {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 428
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/"); tag == "*" || tag == etag(version) {
+			return 0
+		}
+	}
+
+	return 412
+}
+
 func asInt(value string, defaults int) int {
 	if value == "" {
 		return defaults
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nrocco/bookmarks/storage"
+	"github.com/rs/zerolog"
+)
+
+func upload(t *testing.T, api *API, thought *storage.Thought, files map[string]string) *httptest.ResponseRecorder {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if content, ok := files[name]; ok {
+			part, err := writer.CreateFormFile("file", name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write([]byte(content))
+		}
+	}
+	writer.Close()
+
+	r := httptest.NewRequest("POST", "/api/thoughts/"+thought.ID+"/attachments", body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestUploadAllOrNothing(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	store.AttachmentMaxSize = 16
+
+	thought := storage.Thought{Content: "# Files"}
+	if err := store.ThoughtPersist(ctx, &thought); err != nil {
+		t.Fatal(err)
+	}
+
+	api := New(zerolog.Nop(), store, "", "", "")
+
+	if w := upload(t, api, &thought, map[string]string{"a.txt": "small", "b.txt": strings.Repeat("x", 17)}); w.Code != 413 {
+		t.Fatalf("Expected 413 for a file that is too large but got %d", w.Code)
+	}
+
+	if attachments, _ := store.AttachmentList(ctx, &thought); len(*attachments) != 0 {
+		t.Fatalf("Expected no files to be stored but got %d", len(*attachments))
+	}
+
+	if w := upload(t, api, &thought, map[string]string{"a.txt": "small", "b.txt": "also small"}); w.Code != 201 {
+		t.Fatalf("Expected 201 but got %d: %s", w.Code, w.Body.String())
+	}
+
+	if attachments, _ := store.AttachmentList(ctx, &thought); len(*attachments) != 2 {
+		t.Fatalf("Expected 2 stored files but got %d", len(*attachments))
+	}
+}
+
+func TestUploadRequestLimit(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	store.AttachmentMaxSize = 0
+	store.AttachmentQuota = 1024
+
+	thought := storage.Thought{Content: "# Files"}
+	if err := store.ThoughtPersist(ctx, &thought); err != nil {
+		t.Fatal(err)
+	}
+
+	api := New(zerolog.Nop(), store, "", "", "")
+
+	// The request is refused while it is read, before the whole file is in memory
+	w := upload(t, api, &thought, map[string]string{"a.txt": strings.Repeat("x", 2*uploadOverhead)})
+	if w.Code != 413 || !strings.Contains(w.Body.String(), "request body too large") {
+		t.Fatalf("Expected 413 for a request larger than the remaining quota but got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := upload(t, api, &thought, map[string]string{"a.txt": "small"}); w.Code != 201 {
+		t.Fatalf("Expected 201 but got %d: %s", w.Code, w.Body.String())
+	}
+}
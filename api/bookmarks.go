@@ -29,6 +29,7 @@ func (api bookmarks) Routes() chi.Router {
 		r.Get("/", api.get)
 		r.Patch("/", api.update)
 		r.Delete("/", api.delete)
+		r.Get("/links", api.links)
 	})
 
 	return r
@@ -36,10 +37,14 @@ func (api bookmarks) Routes() chi.Router {
 
 func (api *bookmarks) list(w http.ResponseWriter, r *http.Request) {
 	bookmarks, totalCount := api.store.BookmarkList(r.Context(), &storage.BookmarkListOptions{
-		Search: r.URL.Query().Get("q"),
-		Tags:   strings.Split(r.URL.Query().Get("tags"), ","),
-		Limit:  asInt(r.URL.Query().Get("_limit"), 50),
-		Offset: asInt(r.URL.Query().Get("_offset"), 0),
+		Search:   r.URL.Query().Get("q"),
+		Tags:     strings.Split(r.URL.Query().Get("tags"), ","),
+		Author:   r.URL.Query().Get("author"),
+		SiteName: r.URL.Query().Get("site"),
+		Language: r.URL.Query().Get("lang"),
+		Sort:     r.URL.Query().Get("_sort"),
+		Limit:    asInt(r.URL.Query().Get("_limit"), 50),
+		Offset:   asInt(r.URL.Query().Get("_offset"), 0),
 	})
 
 	w.Header().Set("X-Pagination-Total", strconv.Itoa(totalCount))
@@ -107,12 +112,26 @@ func (api *bookmarks) middleware(next http.Handler) http.Handler {
 func (api *bookmarks) get(w http.ResponseWriter, r *http.Request) {
 	bookmark := r.Context().Value(contextKeyBookmark).(*storage.Bookmark)
 
+	w.Header().Set("ETag", etag(bookmark.Version))
+
+	if r.Header.Get("If-None-Match") == etag(bookmark.Version) {
+		w.WriteHeader(304)
+		return
+	}
+
 	jsonResponse(w, 200, bookmark)
 }
 
 func (api *bookmarks) update(w http.ResponseWriter, r *http.Request) {
 	bookmark := r.Context().Value(contextKeyBookmark).(*storage.Bookmark)
 
+	if status := ifMatch(r, bookmark.Version); status != 0 {
+		jsonError(w, http.StatusText(status), status)
+		return
+	}
+
+	version := bookmark.Version
+
 	decoder := json.NewDecoder(r.Body)
 	defer r.Body.Close()
 
@@ -121,11 +140,19 @@ func (api *bookmarks) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := api.store.BookmarkPersist(r.Context(), bookmark); err != nil {
+	// The version is only taken from the If-Match header
+	bookmark.Version = version
+
+	if err := api.store.BookmarkPersist(r.Context(), bookmark); err == storage.ErrVersionConflict {
+		jsonError(w, err.Error(), 412)
+		return
+	} else if err != nil {
 		jsonError(w, err.Error(), 500)
 		return
 	}
 
+	w.Header().Set("ETag", etag(bookmark.Version))
+
 	jsonResponse(w, 200, bookmark)
 }
 
@@ -139,3 +166,15 @@ func (api *bookmarks) delete(w http.ResponseWriter, r *http.Request) {
 
 	jsonResponse(w, 204, nil)
 }
+
+func (api *bookmarks) links(w http.ResponseWriter, r *http.Request) {
+	bookmark := r.Context().Value(contextKeyBookmark).(*storage.Bookmark)
+
+	links, err := api.store.BookmarkLinks(r.Context(), bookmark)
+	if err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	jsonResponse(w, 200, links)
+}
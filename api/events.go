@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nrocco/bookmarks/storage"
+)
+
+type events struct {
+	store *storage.Store
+}
+
+// stream sends changes as server-sent events. A client that reconnects with
+// a Last-Event-ID header first receives the events it missed. The events can
+// be filtered with ?types=bookmark,thought.deleted matching the type or its prefix.
+func (api *events) stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		jsonError(w, "Streaming is not supported", 500)
+		return
+	}
+
+	types := []string{}
+	if value := r.URL.Query().Get("types"); value != "" {
+		types = strings.Split(value, ",")
+	}
+
+	lastID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+	if lastID == 0 {
+		lastID, _ = strconv.ParseInt(r.URL.Query().Get("lastEventId"), 10, 64)
+	}
+
+	// Subscribe before loading the missed events so nothing falls in between
+	subscription, unsubscribe := api.store.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+
+	fmt.Fprintf(w, "retry: 3000\n\n")
+
+	send := func(event *storage.Event) {
+		if event.ID <= lastID || !event.Matches(types) {
+			return
+		}
+
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+		lastID = event.ID
+	}
+
+	if lastID > 0 {
+		missed, err := api.store.EventList(r.Context(), lastID, -1)
+		if err != nil {
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", strconv.Quote(err.Error()))
+			return
+		}
+
+		for _, event := range missed {
+			send(event)
+		}
+	}
+
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": ping\n\n")
+		case event, ok := <-subscription:
+			if !ok {
+				// The client fell behind and resumes from lastID when it reconnects
+				return
+			}
+			send(event)
+		}
+
+		flusher.Flush()
+	}
+}
@@ -15,6 +15,9 @@ var (
 	contextKeyFeed = contextKey("feed")
 )
 
+// errFeedNoSecret is returned if feed credentials are sent but the server has no secret to encrypt them
+const errFeedNoSecret = "Feed credentials can only be stored if the server is started with a secret"
+
 type feeds struct {
 	store *storage.Store
 }
@@ -30,8 +33,9 @@ func (api feeds) Routes() chi.Router {
 		r.Patch("/", api.updateFeed)
 		r.Delete("/", api.deleteFeed)
 		r.Post("/refresh", api.refreshFeed)
-		r.Route("/items/{id}", func(r chi.Router) {
+		r.Route("/items/{itemId}", func(r chi.Router) {
 			r.Delete("/", api.deleteFeedItem)
+			r.Post("/bookmark", api.bookmarkFeedItem)
 		})
 	})
 
@@ -62,7 +66,10 @@ func (api *feeds) createFeed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := api.store.FeedPersist(r.Context(), &feed); err != nil {
+	if err := api.store.FeedPersist(r.Context(), &feed); err == storage.ErrNoSecret {
+		jsonError(w, errFeedNoSecret, 400)
+		return
+	} else if err != nil {
 		jsonError(w, err.Error(), 500)
 		return
 	}
@@ -93,7 +100,11 @@ func (api *feeds) refreshFeed(w http.ResponseWriter, r *http.Request) {
 	feed := r.Context().Value(contextKeyFeed).(*storage.Feed)
 
 	if err := api.store.FeedRefresh(r.Context(), feed); err != nil {
-		jsonError(w, err.Error(), 500)
+		if _, ok := err.(*storage.HTTPError); ok {
+			jsonError(w, err.Error(), 502)
+		} else {
+			jsonError(w, err.Error(), 500)
+		}
 		return
 	}
 
@@ -117,7 +128,10 @@ func (api *feeds) updateFeed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := api.store.FeedPersist(r.Context(), feed); err != nil {
+	if err := api.store.FeedPersist(r.Context(), feed); err == storage.ErrNoSecret {
+		jsonError(w, errFeedNoSecret, 400)
+		return
+	} else if err != nil {
 		jsonError(w, err.Error(), 500)
 		return
 	}
@@ -139,7 +153,7 @@ func (api *feeds) deleteFeed(w http.ResponseWriter, r *http.Request) {
 func (api *feeds) deleteFeedItem(w http.ResponseWriter, r *http.Request) {
 	feed := r.Context().Value(contextKeyFeed).(*storage.Feed)
 
-	if err := feed.DeleteItem(chi.URLParam(r, "id")); err != nil {
+	if err := feed.DeleteItem(chi.URLParam(r, "itemId")); err != nil {
 		jsonError(w, err.Error(), 404)
 		return
 	}
@@ -151,3 +165,21 @@ func (api *feeds) deleteFeedItem(w http.ResponseWriter, r *http.Request) {
 
 	jsonResponse(w, 204, nil)
 }
+
+func (api *feeds) bookmarkFeedItem(w http.ResponseWriter, r *http.Request) {
+	feed := r.Context().Value(contextKeyFeed).(*storage.Feed)
+
+	item := feed.GetItem(chi.URLParam(r, "itemId"))
+	if item == nil {
+		jsonError(w, storage.ErrNotExistingFeedItem.Error(), 404)
+		return
+	}
+
+	bookmark, err := api.store.FeedItemBookmark(r.Context(), feed, item)
+	if err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	jsonResponse(w, 200, bookmark)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nrocco/bookmarks/storage"
+)
+
+func TestFeedCredentialsWithoutSecret(t *testing.T) {
+	store := newTestStore(t)
+
+	feed := storage.Feed{URL: "https://example.com/feed.atom"}
+	if err := store.FeedPersist(context.Background(), &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("PATCH", "/"+feed.ID, strings.NewReader(`{"Credentials":{"Username":"user","Password":"pass"}}`))
+
+	w := httptest.NewRecorder()
+	feeds{store}.Routes().ServeHTTP(w, r)
+
+	if w.Code != 400 || !strings.Contains(w.Body.String(), "secret") {
+		t.Fatalf("Expected 400 for credentials without a secret but got %d: %s", w.Code, w.Body.String())
+	}
+
+	if count := store.FeedCredentialsCount(context.Background()); count != 0 {
+		t.Fatalf("Expected no stored credentials but got %d", count)
+	}
+
+	store.Secret = "secret"
+
+	r = httptest.NewRequest("PATCH", "/"+feed.ID, strings.NewReader(`{"Credentials":{"Username":"user","Password":"pass"}}`))
+
+	w = httptest.NewRecorder()
+	feeds{store}.Routes().ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Fatalf("Expected 200 but got %d: %s", w.Code, w.Body.String())
+	}
+
+	if count := store.FeedCredentialsCount(context.Background()); count != 1 {
+		t.Fatalf("Expected 1 feed with stored credentials but got %d", count)
+	}
+}
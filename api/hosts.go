@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/nrocco/bookmarks/storage"
+)
+
+type hosts struct {
+	fetcher *storage.Fetcher
+}
+
+func (api hosts) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Get("/", api.list)
+
+	return r
+}
+
+func (api *hosts) list(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, 200, api.fetcher.Stats())
+}
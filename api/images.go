@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/nrocco/bookmarks/storage"
+)
+
+type images struct {
+	store *storage.Store
+}
+
+func (api images) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Get("/", api.cache)
+	r.Get("/{hash}", api.get)
+
+	return r
+}
+
+func (api *images) cache(w http.ResponseWriter, r *http.Request) {
+	url := r.URL.Query().Get("url")
+	if url == "" {
+		jsonError(w, "Missing url", 400)
+		return
+	}
+
+	cached, err := api.store.ImageCache(r.Context(), url)
+	if err == storage.ErrImageNotReferenced {
+		jsonError(w, err.Error(), 404)
+		return
+	} else if err != nil {
+		jsonError(w, err.Error(), 502)
+		return
+	}
+
+	http.Redirect(w, r, strings.TrimSuffix(r.URL.Path, "/")+"/"+cached.ID, 302)
+}
+
+func (api *images) get(w http.ResponseWriter, r *http.Request) {
+	cached := storage.Image{ID: chi.URLParam(r, "hash")}
+
+	if err := api.store.ImageGet(r.Context(), &cached); err != nil {
+		jsonError(w, "Image Not Found", 404)
+		return
+	}
+
+	w.Header().Set("Content-Type", cached.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(cached.Size))
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=604800") // 1 week
+	w.WriteHeader(200)
+	w.Write(cached.Data)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/nrocco/bookmarks/storage"
+)
+
+var (
+	contextKeyReminder = contextKey("reminder")
+)
+
+type reminders struct {
+	store *storage.Store
+}
+
+// reminderRequest is the body of a create or update request, Due is parsed by storage.ParseReminderDue
+type reminderRequest struct {
+	Type     string
+	TargetID string
+	Due      string
+	Note     *string
+}
+
+func (api reminders) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Get("/", api.list)
+	r.Post("/", api.create)
+	r.Route("/{id}", func(r chi.Router) {
+		r.Use(api.middleware)
+		r.Get("/", api.get)
+		r.Patch("/", api.update)
+		r.Delete("/", api.delete)
+	})
+
+	return r
+}
+
+func (api *reminders) list(w http.ResponseWriter, r *http.Request) {
+	reminders, totalCount := api.store.ReminderList(r.Context(), &storage.ReminderListOptions{
+		Type:     r.URL.Query().Get("type"),
+		TargetID: r.URL.Query().Get("target"),
+		Status:   r.URL.Query().Get("status"),
+		Limit:    asInt(r.URL.Query().Get("_limit"), 50),
+		Offset:   asInt(r.URL.Query().Get("_offset"), 0),
+	})
+
+	w.Header().Set("X-Pagination-Total", strconv.Itoa(totalCount))
+
+	jsonResponse(w, 200, reminders)
+}
+
+func (api *reminders) create(w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), contextKeyReminder, &storage.Reminder{})
+	api.update(w, r.WithContext(ctx))
+}
+
+func (api *reminders) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reminder := storage.Reminder{ID: chi.URLParam(r, "id")}
+
+		if err := api.store.ReminderGet(r.Context(), &reminder); err != nil {
+			jsonError(w, "Reminder Not Found", 404)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyReminder, &reminder)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (api *reminders) get(w http.ResponseWriter, r *http.Request) {
+	reminder := r.Context().Value(contextKeyReminder).(*storage.Reminder)
+
+	jsonResponse(w, 200, reminder)
+}
+
+func (api *reminders) update(w http.ResponseWriter, r *http.Request) {
+	reminder := r.Context().Value(contextKeyReminder).(*storage.Reminder)
+
+	var request reminderRequest
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	if err := decoder.Decode(&request); err != nil {
+		jsonError(w, err.Error(), 400)
+		return
+	}
+
+	// The bookmark or thought of an existing reminder cannot be changed
+	if reminder.ID == "" {
+		reminder.Type = request.Type
+		reminder.TargetID = request.TargetID
+	}
+
+	if request.Note != nil {
+		reminder.Note = *request.Note
+	}
+
+	if request.Due != "" {
+		due, err := storage.ParseReminderDue(request.Due, time.Now())
+		if err != nil {
+			jsonError(w, err.Error(), 400)
+			return
+		}
+		reminder.Due = due
+	}
+
+	if err := api.store.ReminderPersist(r.Context(), reminder); err == storage.ErrInvalidReminder {
+		jsonError(w, err.Error(), 400)
+		return
+	} else if err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	jsonResponse(w, 200, reminder)
+}
+
+func (api *reminders) delete(w http.ResponseWriter, r *http.Request) {
+	reminder := r.Context().Value(contextKeyReminder).(*storage.Reminder)
+
+	if err := api.store.ReminderDelete(r.Context(), reminder); err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	jsonResponse(w, 204, nil)
+}
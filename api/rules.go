@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/nrocco/bookmarks/storage"
+)
+
+var (
+	contextKeyRule = contextKey("rule")
+)
+
+type rules struct {
+	store *storage.Store
+}
+
+func (api rules) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Get("/", api.list)
+	r.Post("/", api.create)
+	r.Post("/_preview", api.preview)
+	r.Route("/{id}", func(r chi.Router) {
+		r.Use(api.middleware)
+		r.Get("/", api.get)
+		r.Patch("/", api.update)
+		r.Delete("/", api.delete)
+		r.Get("/preview", api.previewRule)
+	})
+
+	return r
+}
+
+func (api *rules) list(w http.ResponseWriter, r *http.Request) {
+	rules, totalCount := api.store.RuleList(r.Context(), &storage.RuleListOptions{
+		Limit:  asInt(r.URL.Query().Get("_limit"), 50),
+		Offset: asInt(r.URL.Query().Get("_offset"), 0),
+	})
+
+	w.Header().Set("X-Pagination-Total", strconv.Itoa(totalCount))
+
+	jsonResponse(w, 200, rules)
+}
+
+func (api *rules) create(w http.ResponseWriter, r *http.Request) {
+	var rule storage.Rule
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	if err := decoder.Decode(&rule); err != nil {
+		jsonError(w, err.Error(), 400)
+		return
+	}
+
+	if err := rule.Validate(); err != nil {
+		jsonError(w, err.Error(), 400)
+		return
+	}
+
+	if err := api.store.RulePersist(r.Context(), &rule); err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	jsonResponse(w, 200, &rule)
+}
+
+func (api *rules) preview(w http.ResponseWriter, r *http.Request) {
+	var rule storage.Rule
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	if err := decoder.Decode(&rule); err != nil {
+		jsonError(w, err.Error(), 400)
+		return
+	}
+
+	matches, err := api.store.RulePreview(r.Context(), &rule)
+	if err != nil {
+		jsonError(w, err.Error(), 400)
+		return
+	}
+
+	jsonResponse(w, 200, matches)
+}
+
+func (api *rules) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule := storage.Rule{ID: chi.URLParam(r, "id")}
+
+		if err := api.store.RuleGet(r.Context(), &rule); err != nil {
+			jsonError(w, "Rule Not Found", 404)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyRule, &rule)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (api *rules) get(w http.ResponseWriter, r *http.Request) {
+	rule := r.Context().Value(contextKeyRule).(*storage.Rule)
+
+	jsonResponse(w, 200, rule)
+}
+
+func (api *rules) update(w http.ResponseWriter, r *http.Request) {
+	rule := r.Context().Value(contextKeyRule).(*storage.Rule)
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	if err := decoder.Decode(rule); err != nil {
+		jsonError(w, err.Error(), 400)
+		return
+	}
+
+	if err := rule.Validate(); err != nil {
+		jsonError(w, err.Error(), 400)
+		return
+	}
+
+	if err := api.store.RulePersist(r.Context(), rule); err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	jsonResponse(w, 200, rule)
+}
+
+func (api *rules) delete(w http.ResponseWriter, r *http.Request) {
+	rule := r.Context().Value(contextKeyRule).(*storage.Rule)
+
+	if err := api.store.RuleDelete(r.Context(), rule); err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	jsonResponse(w, 204, nil)
+}
+
+func (api *rules) previewRule(w http.ResponseWriter, r *http.Request) {
+	rule := r.Context().Value(contextKeyRule).(*storage.Rule)
+
+	matches, err := api.store.RulePreview(r.Context(), rule)
+	if err != nil {
+		jsonError(w, err.Error(), 400)
+		return
+	}
+
+	jsonResponse(w, 200, matches)
+}
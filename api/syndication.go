@@ -0,0 +1,443 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi"
+	"github.com/nrocco/bookmarks/storage"
+)
+
+type syndication struct {
+	store  *storage.Store
+	secret string
+}
+
+func (api syndication) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(api.authenticator)
+	r.Get("/bookmarks.atom", api.bookmarksAtom)
+	r.Get("/bookmarks.rss", api.bookmarksRss)
+	r.Get("/thoughts.atom", api.thoughtsAtom)
+	r.Get("/thoughts.rss", api.thoughtsRss)
+	r.Get("/reminders.ics", api.remindersIcs)
+
+	return r
+}
+
+// syndicationMaxLimit is the maximum number of entries of an outbound feed
+const syndicationMaxLimit = 500
+
+// syndicationFilters are the query parameters that are covered by the token of a feed
+var syndicationFilters = []string{"q", "tags", "_limit"}
+
+// token returns the secret token that grants access to the feed with the
+// given name and filters, the message is prefixed so a token never equals
+// the mac of the login cookie
+func (api *syndication) token(name string, query url.Values) string {
+	hash := hmac.New(sha256.New, []byte(api.secret))
+	io.WriteString(hash, "feed:"+name+"?"+syndicationQuery(query).Encode())
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// list returns the urls of all outbound feeds including their secret token,
+// the filters of the request are added to the urls of the bookmarks and
+// thoughts feeds
+func (api *syndication) list(w http.ResponseWriter, r *http.Request) {
+	feeds := map[string]string{}
+
+	for _, name := range []string{"bookmarks.atom", "bookmarks.rss", "thoughts.atom", "thoughts.rss", "reminders.ics"} {
+		query := url.Values{}
+		if !strings.HasPrefix(name, "reminders.") {
+			query = syndicationQuery(r.URL.Query())
+		}
+
+		if api.secret != "" {
+			query.Set("token", api.token(strings.Split(name, ".")[0], query))
+		}
+
+		feeds[name] = baseURL(r) + "/feeds/" + name
+		if len(query) > 0 {
+			feeds[name] += "?" + query.Encode()
+		}
+	}
+
+	jsonResponse(w, 200, feeds)
+}
+
+func (api *syndication) authenticator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if api.secret == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name := strings.Split(strings.TrimPrefix(r.URL.Path, "/feeds/"), ".")[0]
+
+		if !hmac.Equal([]byte(api.token(name, r.URL.Query())), []byte(r.URL.Query().Get("token"))) {
+			time.Sleep(2 * time.Second)
+			w.WriteHeader(401)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// syndicationQuery returns the non empty filters of the query
+func syndicationQuery(query url.Values) url.Values {
+	filters := url.Values{}
+
+	for _, key := range syndicationFilters {
+		if value := query.Get(key); value != "" {
+			filters.Set(key, value)
+		}
+	}
+
+	return filters
+}
+
+// syndicationLimit returns the _limit of the request, at most syndicationMaxLimit
+func syndicationLimit(r *http.Request, defaults int) int {
+	limit := asInt(r.URL.Query().Get("_limit"), defaults)
+	if limit <= 0 || limit > syndicationMaxLimit {
+		return syndicationMaxLimit
+	}
+
+	return limit
+}
+
+func (api *syndication) bookmarks(r *http.Request) *syndicationFeed {
+	bookmarks, _ := api.store.BookmarkList(r.Context(), &storage.BookmarkListOptions{
+		Search: r.URL.Query().Get("q"),
+		Tags:   strings.Split(r.URL.Query().Get("tags"), ","),
+		Limit:  syndicationLimit(r, 50),
+	})
+
+	feed := &syndicationFeed{
+		Title: "Bookmarks",
+		Link:  baseURL(r) + "/",
+		Self:  baseURL(r) + r.URL.RequestURI(),
+	}
+
+	for _, bookmark := range *bookmarks {
+		feed.Entries = append(feed.Entries, &syndicationEntry{
+			ID:      bookmark.URL,
+			Title:   bookmark.Title,
+			Link:    bookmark.URL,
+			Created: bookmark.Created,
+			Updated: bookmark.Updated,
+			Content: bookmark.Excerpt,
+			Tags:    bookmark.Tags,
+		})
+	}
+
+	return feed
+}
+
+func (api *syndication) thoughts(r *http.Request) *syndicationFeed {
+	thoughts, _ := api.store.ThoughtList(r.Context(), &storage.ThoughtListOptions{
+		Search: r.URL.Query().Get("q"),
+		Tags:   strings.Split(r.URL.Query().Get("tags"), ","),
+		Limit:  syndicationLimit(r, 50),
+	})
+
+	feed := &syndicationFeed{
+		Title: "Thoughts",
+		Link:  baseURL(r) + "/#/thoughts",
+		Self:  baseURL(r) + r.URL.RequestURI(),
+	}
+
+	for _, thought := range *thoughts {
+		link := baseURL(r) + "/#/thoughts/" + thought.ID
+
+		entry := &syndicationEntry{
+			ID:      link,
+			Title:   thought.Title(),
+			Link:    link,
+			Created: thought.Created,
+			Updated: thought.Updated,
+			Content: thought.Content,
+			Tags:    thought.Tags,
+		}
+
+		if rendered, err := thought.HTML(); err == nil {
+			entry.Content = rendered
+			entry.HTML = true
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed
+}
+
+func (api *syndication) bookmarksAtom(w http.ResponseWriter, r *http.Request) {
+	xmlResponse(w, "application/atom+xml", api.bookmarks(r).Atom())
+}
+
+func (api *syndication) bookmarksRss(w http.ResponseWriter, r *http.Request) {
+	xmlResponse(w, "application/rss+xml", api.bookmarks(r).Rss())
+}
+
+func (api *syndication) thoughtsAtom(w http.ResponseWriter, r *http.Request) {
+	xmlResponse(w, "application/atom+xml", api.thoughts(r).Atom())
+}
+
+func (api *syndication) thoughtsRss(w http.ResponseWriter, r *http.Request) {
+	xmlResponse(w, "application/rss+xml", api.thoughts(r).Rss())
+}
+
+func (api *syndication) remindersIcs(w http.ResponseWriter, r *http.Request) {
+	reminders, _ := api.store.ReminderList(r.Context(), &storage.ReminderListOptions{
+		Status: storage.ReminderPending,
+		Limit:  syndicationLimit(r, syndicationMaxLimit),
+	})
+
+	var buffer bytes.Buffer
+
+	calendar := icsWriter{&buffer}
+	calendar.line("BEGIN:VCALENDAR")
+	calendar.line("VERSION:2.0")
+	calendar.line("PRODID:-//nrocco//bookmarks//EN")
+	calendar.line("CALSCALE:GREGORIAN")
+	calendar.line("X-WR-CALNAME:Reminders")
+
+	for _, reminder := range *reminders {
+		link := reminder.URL
+		if link == "" && reminder.Type == storage.ReminderTypeThought {
+			link = baseURL(r) + "/#/thoughts/" + reminder.TargetID
+		}
+
+		description := reminder.Note
+		if link != "" {
+			description = strings.TrimSpace(description + "\n\n" + link)
+		}
+
+		calendar.line("BEGIN:VEVENT")
+		calendar.line("UID:" + reminder.ID + "@bookmarks")
+		calendar.line("DTSTAMP:" + icsTime(reminder.Created))
+		calendar.line("DTSTART:" + icsTime(reminder.Due))
+		calendar.line("DURATION:PT15M")
+		calendar.line("SUMMARY:" + icsText(reminder.Title))
+		calendar.line("DESCRIPTION:" + icsText(description))
+		if link != "" {
+			calendar.line("URL:" + link)
+		}
+		calendar.line("BEGIN:VALARM")
+		calendar.line("ACTION:DISPLAY")
+		calendar.line("TRIGGER:PT0M")
+		calendar.line("DESCRIPTION:" + icsText(reminder.Title))
+		calendar.line("END:VALARM")
+		calendar.line("END:VEVENT")
+	}
+
+	calendar.line("END:VCALENDAR")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write(buffer.Bytes())
+}
+
+func xmlResponse(w http.ResponseWriter, contentType string, object interface{}) {
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.WriteHeader(200)
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(object)
+}
+
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	return scheme + "://" + r.Host
+}
+
+type syndicationFeed struct {
+	Title   string
+	Link    string
+	Self    string
+	Entries []*syndicationEntry
+}
+
+type syndicationEntry struct {
+	ID      string
+	Title   string
+	Link    string
+	Created time.Time
+	Updated time.Time
+	Content string
+	HTML    bool
+	Tags    storage.Tags
+}
+
+func (feed *syndicationFeed) updated() time.Time {
+	updated := time.Unix(0, 0)
+
+	for _, entry := range feed.Entries {
+		if entry.Updated.After(updated) {
+			updated = entry.Updated
+		}
+	}
+
+	return updated
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Content    atomText       `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string       `xml:"id"`
+	Title   string       `xml:"title"`
+	Updated string       `xml:"updated"`
+	Author  string       `xml:"author>name"`
+	Links   []atomLink   `xml:"link"`
+	Entries []*atomEntry `xml:"entry"`
+}
+
+// Atom renders the feed as an Atom 1.0 document
+func (feed *syndicationFeed) Atom() *atomFeed {
+	atom := &atomFeed{
+		ID:      feed.Self,
+		Title:   feed.Title,
+		Updated: feed.updated().Format(time.RFC3339),
+		Author:  "bookmarks",
+		Links:   []atomLink{{Href: feed.Link}, {Href: feed.Self, Rel: "self"}},
+	}
+
+	for _, entry := range feed.Entries {
+		atomEntry := &atomEntry{
+			ID:        entry.ID,
+			Title:     entry.Title,
+			Link:      atomLink{Href: entry.Link},
+			Published: entry.Created.Format(time.RFC3339),
+			Updated:   entry.Updated.Format(time.RFC3339),
+			Content:   atomText{Type: "text", Body: entry.Content},
+		}
+
+		if entry.HTML {
+			atomEntry.Content.Type = "html"
+		}
+
+		for _, tag := range entry.Tags {
+			atomEntry.Categories = append(atomEntry.Categories, atomCategory{Term: tag})
+		}
+
+		atom.Entries = append(atom.Entries, atomEntry)
+	}
+
+	return atom
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description"`
+	Categories  []string `xml:"category"`
+}
+
+type rssFeed struct {
+	XMLName       xml.Name   `xml:"rss"`
+	Version       string     `xml:"version,attr"`
+	Title         string     `xml:"channel>title"`
+	Link          string     `xml:"channel>link"`
+	Description   string     `xml:"channel>description"`
+	LastBuildDate string     `xml:"channel>lastBuildDate"`
+	Items         []*rssItem `xml:"channel>item"`
+}
+
+// Rss renders the feed as a RSS 2.0 document
+func (feed *syndicationFeed) Rss() *rssFeed {
+	rss := &rssFeed{
+		Version:       "2.0",
+		Title:         feed.Title,
+		Link:          feed.Link,
+		Description:   feed.Title,
+		LastBuildDate: feed.updated().Format(time.RFC1123Z),
+	}
+
+	for _, entry := range feed.Entries {
+		rss.Items = append(rss.Items, &rssItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			GUID:        rssGUID{IsPermaLink: entry.ID == entry.Link, Value: entry.ID},
+			PubDate:     entry.Created.Format(time.RFC1123Z),
+			Description: entry.Content,
+			Categories:  entry.Tags,
+		})
+	}
+
+	return rss
+}
+
+// icsWriter writes iCalendar content lines, folded at 75 octets
+type icsWriter struct {
+	w io.Writer
+}
+
+func (calendar icsWriter) line(line string) {
+	// Continuation lines start with a space, which counts towards the limit
+	for cut := 75; len(line) > cut; cut = 74 {
+		for !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		io.WriteString(calendar.w, line[:cut]+"\r\n ")
+		line = line[cut:]
+	}
+
+	io.WriteString(calendar.w, line+"\r\n")
+}
+
+// icsTime formats a time as an iCalendar utc date-time
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icsText escapes an iCalendar text value
+func icsText(text string) string {
+	return strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\r\n", "\\n", "\n", "\\n").Replace(text)
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/nrocco/bookmarks/storage"
+)
+
+func newTestStore(t *testing.T) *storage.Store {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	store, err := storage.New(context.Background(), filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func newTestSyndication(t *testing.T) (*syndication, http.Handler) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	for _, bookmark := range []*storage.Bookmark{
+		{URL: "https://golang.org/", Title: "Go", Excerpt: "The Go language", Tags: storage.Tags{"golang"}},
+		{URL: "https://example.com/", Title: "Example", Tags: storage.Tags{"private"}},
+	} {
+		if err := store.BookmarkPersist(ctx, bookmark); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.ThoughtPersist(ctx, &storage.Thought{Content: "# Hello\n\nWorld", Tags: storage.Tags{"golang"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	api := &syndication{store, "secret"}
+
+	r := chi.NewRouter()
+	r.Get("/api/syndication", api.list)
+	r.Mount("/feeds", api.Routes())
+
+	return api, r
+}
+
+func get(handler http.Handler, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+
+	return w
+}
+
+func TestSyndicationToken(t *testing.T) {
+	api, handler := newTestSyndication(t)
+
+	w := get(handler, "/api/syndication?tags=golang&other=ignored")
+
+	feeds := map[string]string{}
+	if err := json.NewDecoder(w.Body).Decode(&feeds); err != nil {
+		t.Fatal(err)
+	}
+
+	location, err := url.Parse(feeds["bookmarks.atom"])
+	if err != nil || location.Query().Get("tags") != "golang" || location.Query().Get("other") != "" || location.Query().Get("token") == "" {
+		t.Fatalf("Expected a feed url with the tags filter and a token but got %s", feeds["bookmarks.atom"])
+	}
+
+	if w := get(handler, location.RequestURI()); w.Code != 200 {
+		t.Fatalf("Expected 200 for the listed url but got %d", w.Code)
+	}
+
+	if w := get(handler, strings.Replace(location.RequestURI(), "tags=golang", "tags=private", 1)); w.Code != 401 {
+		t.Fatalf("Expected 401 after changing the filter but got %d", w.Code)
+	}
+
+	if api.token("bookmarks", url.Values{"tags": {"golang"}}) == api.token("bookmarks", url.Values{"tags": {"golang"}, "_limit": {"-1"}}) {
+		t.Fatal("Expected the limit to be covered by the token")
+	}
+
+	// The mac of the login cookie of a user named bookmarks must not be a valid token
+	hash := hmac.New(sha256.New, []byte("secret"))
+	io.WriteString(hash, "bookmarks")
+	if api.token("bookmarks", url.Values{}) == hex.EncodeToString(hash.Sum(nil)) {
+		t.Fatal("Expected the token to differ from the mac of the login cookie")
+	}
+}
+
+func TestSyndicationAtom(t *testing.T) {
+	api, handler := newTestSyndication(t)
+
+	query := url.Values{"tags": {"golang"}}
+	query.Set("token", api.token("bookmarks", query))
+
+	w := get(handler, "/feeds/bookmarks.atom?"+query.Encode())
+	if w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/atom+xml") {
+		t.Fatalf("Expected an atom feed but got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	feed := atomFeed{}
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	if feed.Title != "Bookmarks" || len(feed.Links) != 2 || feed.Links[1].Rel != "self" {
+		t.Fatalf("Expected the bookmarks feed with a self link but got %+v", feed)
+	}
+
+	if len(feed.Entries) != 1 {
+		t.Fatalf("Expected 1 entry but got %d", len(feed.Entries))
+	}
+
+	entry := feed.Entries[0]
+	if entry.ID != "https://golang.org/" || entry.Title != "Go" || entry.Content.Type != "text" || entry.Content.Body != "The Go language" || len(entry.Categories) != 1 || entry.Categories[0].Term != "golang" {
+		t.Fatalf("Unexpected entry %+v", entry)
+	}
+
+	query = url.Values{}
+	query.Set("token", api.token("thoughts", query))
+
+	feed = atomFeed{}
+	if err := xml.Unmarshal(get(handler, "/feeds/thoughts.atom?"+query.Encode()).Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(feed.Entries) != 1 || feed.Entries[0].Title != "Hello" || feed.Entries[0].Content.Type != "html" || !strings.Contains(feed.Entries[0].Content.Body, "<h1") {
+		t.Fatalf("Expected the thought rendered as html but got %+v", feed.Entries)
+	}
+}
+
+func TestSyndicationRss(t *testing.T) {
+	api, handler := newTestSyndication(t)
+
+	query := url.Values{}
+	query.Set("token", api.token("bookmarks", query))
+
+	w := get(handler, "/feeds/bookmarks.rss?"+query.Encode())
+	if w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/rss+xml") {
+		t.Fatalf("Expected a rss feed but got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	feed := rssFeed{}
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	if feed.Version != "2.0" || feed.Title != "Bookmarks" || len(feed.Items) != 2 {
+		t.Fatalf("Expected a rss 2.0 feed with 2 items but got %+v", feed)
+	}
+
+	for _, item := range feed.Items {
+		if !item.GUID.IsPermaLink || item.GUID.Value != item.Link {
+			t.Fatalf("Expected the link as permalink guid but got %+v", item)
+		}
+	}
+}
+
+func TestSyndicationLimit(t *testing.T) {
+	tests := map[string]int{
+		"":     50,
+		"10":   10,
+		"-1":   syndicationMaxLimit,
+		"0":    syndicationMaxLimit,
+		"9999": syndicationMaxLimit,
+	}
+
+	for value, expected := range tests {
+		r := httptest.NewRequest("GET", "/feeds/bookmarks.atom?_limit="+value, nil)
+		if limit := syndicationLimit(r, 50); limit != expected {
+			t.Fatalf("Expected %d for %q but got %d", expected, value, limit)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/nrocco/bookmarks/storage"
+)
+
+var (
+	contextKeyTask = contextKey("task")
+)
+
+type tasks struct {
+	store *storage.Store
+}
+
+func (api tasks) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Get("/", api.list)
+	r.Route("/{thought}/{line}", func(r chi.Router) {
+		r.Use(api.middleware)
+		r.Get("/", api.get)
+		r.Post("/toggle", api.toggle)
+	})
+
+	return r
+}
+
+func (api *tasks) list(w http.ResponseWriter, r *http.Request) {
+	options := storage.TaskListOptions{
+		ThoughtID: r.URL.Query().Get("thought"),
+		DueBefore: r.URL.Query().Get("due"),
+		Limit:     asInt(r.URL.Query().Get("_limit"), 50),
+		Offset:    asInt(r.URL.Query().Get("_offset"), 0),
+	}
+
+	switch r.URL.Query().Get("done") {
+	case "all":
+		options.All = true
+	case "true", "1":
+		options.Done = true
+	}
+
+	tasks, totalCount := api.store.TaskList(r.Context(), &options)
+
+	w.Header().Set("X-Pagination-Total", strconv.Itoa(totalCount))
+
+	jsonResponse(w, 200, tasks)
+}
+
+func (api *tasks) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		task := storage.Task{ThoughtID: chi.URLParam(r, "thought"), Line: asInt(chi.URLParam(r, "line"), 0)}
+
+		if err := api.store.TaskGet(r.Context(), &task); err != nil {
+			jsonError(w, "Task Not Found", 404)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyTask, &task)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (api *tasks) get(w http.ResponseWriter, r *http.Request) {
+	task := r.Context().Value(contextKeyTask).(*storage.Task)
+
+	jsonResponse(w, 200, task)
+}
+
+func (api *tasks) toggle(w http.ResponseWriter, r *http.Request) {
+	task := r.Context().Value(contextKeyTask).(*storage.Task)
+
+	if err := api.store.TaskToggle(r.Context(), task); err == storage.ErrTaskChanged || err == storage.ErrVersionConflict {
+		jsonError(w, err.Error(), 409)
+		return
+	} else if err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	jsonResponse(w, 200, task)
+}
@@ -1,18 +1,34 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/nrocco/bookmarks/storage"
 )
 
+const (
+	// uploadMaxSize limits the size of an upload request if there is no attachment quota
+	uploadMaxSize = 256 * 1024 * 1024
+
+	// uploadOverhead is allowed on top of the remaining quota for the multipart headers of an upload request
+	uploadOverhead = 64 * 1024
+)
+
 var (
-	contextKeyThought = contextKey("thought")
+	contextKeyThought    = contextKey("thought")
+	contextKeyRevision   = contextKey("revision")
+	contextKeyAttachment = contextKey("attachment")
 )
 
 type thoughts struct {
@@ -24,11 +40,27 @@ func (api thoughts) Routes() chi.Router {
 	r.Get("/", api.list)
 	r.Get("/_tags", api.taglist)
 	r.Post("/", api.create)
+	r.Get("/journal", api.calendar)
+	r.Get("/journal/{date}", api.journal)
 	r.Route("/{id}", func(r chi.Router) {
 		r.Use(api.middleware)
 		r.Get("/", api.get)
 		r.Put("/", api.update)
 		r.Delete("/", api.delete)
+		r.Get("/links", api.links)
+		r.Get("/revisions", api.revisions)
+		r.Route("/revisions/{revision}", func(r chi.Router) {
+			r.Use(api.revisionMiddleware)
+			r.Get("/", api.revision)
+			r.Get("/diff", api.diff)
+			r.Post("/restore", api.restore)
+		})
+		r.Get("/attachments", api.attachments)
+		r.Route("/attachments/{name}", func(r chi.Router) {
+			r.Use(api.attachmentMiddleware)
+			r.Get("/", api.download)
+			r.Delete("/", api.detach)
+		})
 	})
 
 	return r
@@ -58,6 +90,45 @@ func (api *thoughts) create(w http.ResponseWriter, r *http.Request) {
 	api.update(w, r)
 }
 
+func (api *thoughts) calendar(w http.ResponseWriter, r *http.Request) {
+	month, err := time.ParseInLocation("2006-01", r.URL.Query().Get("month"), time.Local)
+	if err != nil {
+		now := time.Now()
+		month = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+
+	jsonResponse(w, 200, api.store.ThoughtJournalCalendar(r.Context(), month, month.AddDate(0, 1, 0)))
+}
+
+func (api *thoughts) journal(w http.ResponseWriter, r *http.Request) {
+	day, err := storage.ParseJournalDate(chi.URLParam(r, "date"))
+	if err != nil {
+		w.Header().Set("X-Error", "Invalid date, expected YYYY-MM-DD")
+		w.WriteHeader(400)
+		return
+	}
+
+	thought, err := api.store.ThoughtJournal(r.Context(), day)
+	if err != nil {
+		w.Header().Set("X-Error", err.Error())
+		w.WriteHeader(500)
+		return
+	}
+
+	previous, next := api.store.ThoughtJournalAdjacent(r.Context(), day)
+	if previous != "" {
+		w.Header().Set("X-Previous", previous)
+	}
+	if next != "" {
+		w.Header().Set("X-Next", next)
+	}
+
+	w.Header().Set("X-Id", thought.ID)
+
+	ctx := context.WithValue(r.Context(), contextKeyThought, thought)
+	api.get(w, r.WithContext(ctx))
+}
+
 func (api *thoughts) middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		thought := storage.Thought{ID: chi.URLParam(r, "id")}
@@ -78,7 +149,29 @@ func (api *thoughts) get(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("X-Created", thought.Created.Format("2006-01-02T15:04:05.0000000Z"))
 	w.Header().Set("X-Updated", thought.Updated.Format("2006-01-02T15:04:05.0000000Z"))
 	w.Header().Set("X-Tags", strings.Join(thought.Tags, ","))
+	w.Header().Set("ETag", etag(thought.Version))
+	w.Header().Set("Vary", "Accept")
 
+	if r.Header.Get("If-None-Match") == etag(thought.Version) {
+		w.WriteHeader(304)
+		return
+	}
+
+	if negotiate(r, "text/plain", "text/html") == "text/html" {
+		rendered, err := thought.HTML()
+		if err != nil {
+			w.Header().Set("X-Error", err.Error())
+			w.WriteHeader(500)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(200)
+		w.Write([]byte(rendered))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(200)
 	w.Write([]byte(thought.Content))
 }
@@ -86,6 +179,12 @@ func (api *thoughts) get(w http.ResponseWriter, r *http.Request) {
 func (api *thoughts) update(w http.ResponseWriter, r *http.Request) {
 	thought := r.Context().Value(contextKeyThought).(*storage.Thought)
 
+	if status := ifMatch(r, thought.Version); thought.ID != "" && status != 0 {
+		w.Header().Set("X-Error", http.StatusText(status))
+		w.WriteHeader(status)
+		return
+	}
+
 	if tags := r.Header.Get("X-Tags"); tags != "" {
 		thought.Tags = strings.Split(tags, ",")
 	}
@@ -103,7 +202,11 @@ func (api *thoughts) update(w http.ResponseWriter, r *http.Request) {
 		thought.Content = string(body)
 	}
 
-	if err := api.store.ThoughtPersist(r.Context(), thought); err != nil {
+	if err := api.store.ThoughtPersist(r.Context(), thought); err == storage.ErrVersionConflict {
+		w.Header().Set("X-Error", err.Error())
+		w.WriteHeader(412)
+		return
+	} else if err != nil {
 		w.Header().Set("X-Error", err.Error())
 		w.WriteHeader(500)
 		return
@@ -113,6 +216,7 @@ func (api *thoughts) update(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("X-Created", thought.Created.Format("2006-01-02T15:04:05.0000000Z"))
 	w.Header().Set("X-Updated", thought.Updated.Format("2006-01-02T15:04:05.0000000Z"))
 	w.Header().Set("X-Tags", strings.Join(thought.Tags, ","))
+	w.Header().Set("ETag", etag(thought.Version))
 
 	w.WriteHeader(200)
 	w.Write([]byte(thought.Content))
@@ -129,3 +233,282 @@ func (api *thoughts) delete(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(204)
 }
+
+func (api *thoughts) links(w http.ResponseWriter, r *http.Request) {
+	thought := r.Context().Value(contextKeyThought).(*storage.Thought)
+
+	links, err := api.store.ThoughtLinks(r.Context(), thought)
+	if err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	jsonResponse(w, 200, links)
+}
+
+func (api *thoughts) revisions(w http.ResponseWriter, r *http.Request) {
+	thought := r.Context().Value(contextKeyThought).(*storage.Thought)
+
+	revisions, err := api.store.ThoughtRevisionList(r.Context(), thought)
+	if err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("X-Pagination-Total", strconv.Itoa(len(*revisions)))
+
+	jsonResponse(w, 200, revisions)
+}
+
+func (api *thoughts) revisionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		thought := r.Context().Value(contextKeyThought).(*storage.Thought)
+		revision := storage.ThoughtRevision{ID: int64(asInt(chi.URLParam(r, "revision"), 0)), ThoughtID: thought.ID}
+
+		if err := api.store.ThoughtRevisionGet(r.Context(), &revision); err != nil {
+			w.WriteHeader(404)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyRevision, &revision)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (api *thoughts) revision(w http.ResponseWriter, r *http.Request) {
+	revision := r.Context().Value(contextKeyRevision).(*storage.ThoughtRevision)
+
+	w.Header().Set("X-Created", revision.Created.Format("2006-01-02T15:04:05.0000000Z"))
+	w.Header().Set("X-Tags", strings.Join(revision.Tags, ","))
+
+	w.WriteHeader(200)
+	w.Write([]byte(revision.Content))
+}
+
+func (api *thoughts) diff(w http.ResponseWriter, r *http.Request) {
+	thought := r.Context().Value(contextKeyThought).(*storage.Thought)
+	revision := r.Context().Value(contextKeyRevision).(*storage.ThoughtRevision)
+
+	to := storage.ThoughtRevision{ThoughtID: thought.ID, Content: thought.Content}
+	toName := "current"
+
+	if id := r.URL.Query().Get("to"); id != "" {
+		to.ID = int64(asInt(id, 0))
+		if err := api.store.ThoughtRevisionGet(r.Context(), &to); err != nil {
+			w.Header().Set("X-Error", "Revision Not Found")
+			w.WriteHeader(404)
+			return
+		}
+		toName = "revision " + id
+	}
+
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write([]byte(storage.UnifiedDiff(revision.Content, to.Content, "revision "+strconv.FormatInt(revision.ID, 10), toName)))
+}
+
+func (api *thoughts) restore(w http.ResponseWriter, r *http.Request) {
+	thought := r.Context().Value(contextKeyThought).(*storage.Thought)
+	revision := r.Context().Value(contextKeyRevision).(*storage.ThoughtRevision)
+
+	if status := ifMatch(r, thought.Version); status != 0 {
+		w.Header().Set("X-Error", http.StatusText(status))
+		w.WriteHeader(status)
+		return
+	}
+
+	if err := api.store.ThoughtRestore(r.Context(), thought, revision); err == storage.ErrVersionConflict {
+		w.Header().Set("X-Error", err.Error())
+		w.WriteHeader(412)
+		return
+	} else if err != nil {
+		w.Header().Set("X-Error", err.Error())
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("X-Id", thought.ID)
+	w.Header().Set("X-Created", thought.Created.Format("2006-01-02T15:04:05.0000000Z"))
+	w.Header().Set("X-Updated", thought.Updated.Format("2006-01-02T15:04:05.0000000Z"))
+	w.Header().Set("X-Tags", strings.Join(thought.Tags, ","))
+	w.Header().Set("ETag", etag(thought.Version))
+
+	w.WriteHeader(200)
+	w.Write([]byte(thought.Content))
+}
+
+func (api *thoughts) attachments(w http.ResponseWriter, r *http.Request) {
+	thought := r.Context().Value(contextKeyThought).(*storage.Thought)
+
+	attachments, err := api.store.AttachmentList(r.Context(), thought)
+	if err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("X-Pagination-Total", strconv.Itoa(len(*attachments)))
+
+	jsonResponse(w, 200, attachments)
+}
+
+func (api *thoughts) upload(w http.ResponseWriter, r *http.Request) {
+	thought := r.Context().Value(contextKeyThought).(*storage.Thought)
+
+	// The files are read into memory, so the whole request can not be larger than what can be stored
+	limit := int64(uploadMaxSize)
+	if api.store.AttachmentQuota > 0 {
+		limit = api.store.AttachmentQuota - api.store.AttachmentUsage(r.Context()) + uploadOverhead
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		jsonError(w, err.Error(), 400)
+		return
+	}
+
+	attachments := []*storage.Attachment{}
+	files := [][]byte{}
+
+	// All files are read and validated before the first one is stored, so a
+	// request that is refused does not leave some of its files behind
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			jsonError(w, err.Error(), uploadErrorStatus(err))
+			return
+		}
+
+		if part.FileName() == "" {
+			continue
+		}
+
+		var data []byte
+		if api.store.AttachmentMaxSize > 0 {
+			data, err = ioutil.ReadAll(io.LimitReader(part, api.store.AttachmentMaxSize+1))
+		} else {
+			data, err = ioutil.ReadAll(part)
+		}
+		part.Close()
+
+		if err != nil {
+			jsonError(w, err.Error(), uploadErrorStatus(err))
+			return
+		}
+
+		attachments = append(attachments, &storage.Attachment{
+			ThoughtID:   thought.ID,
+			Name:        part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Size:        int64(len(data)),
+		})
+		files = append(files, data)
+	}
+
+	if len(attachments) == 0 {
+		jsonError(w, "Missing file", 400)
+		return
+	}
+
+	if err := api.store.AttachmentValidate(r.Context(), attachments); err == storage.ErrAttachmentTooLarge || err == storage.ErrAttachmentQuota {
+		jsonError(w, err.Error(), 413)
+		return
+	} else if err != nil {
+		jsonError(w, err.Error(), 400)
+		return
+	}
+
+	for i, attachment := range attachments {
+		if err := api.store.AttachmentPersist(r.Context(), attachment, bytes.NewReader(files[i])); err != nil {
+			message := err.Error()
+			if i > 0 {
+				stored := []string{}
+				for _, attachment := range attachments[:i] {
+					stored = append(stored, attachment.Name)
+				}
+
+				message = fmt.Sprintf("%s, stored before the error: %s", message, strings.Join(stored, ", "))
+			}
+
+			jsonError(w, message, 500)
+			return
+		}
+	}
+
+	jsonResponse(w, 201, attachments)
+}
+
+// uploadErrorStatus returns 413 if reading an upload request failed because it is too large, 400 otherwise
+func uploadErrorStatus(err error) int {
+	// http.MaxBytesReader does not return a distinct error type
+	if err.Error() == "http: request body too large" {
+		return 413
+	}
+
+	return 400
+}
+
+func (api *thoughts) attachmentMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		thought := r.Context().Value(contextKeyThought).(*storage.Thought)
+		attachment := storage.Attachment{ThoughtID: thought.ID, Name: chi.URLParam(r, "name")}
+
+		if name, err := url.PathUnescape(attachment.Name); err == nil {
+			attachment.Name = name
+		}
+
+		if err := api.store.AttachmentGet(r.Context(), &attachment); err != nil {
+			jsonError(w, "Attachment Not Found", 404)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyAttachment, &attachment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (api *thoughts) download(w http.ResponseWriter, r *http.Request) {
+	attachment := r.Context().Value(contextKeyAttachment).(*storage.Attachment)
+
+	w.Header().Set("ETag", `"`+attachment.Hash+`"`)
+
+	if r.Header.Get("If-None-Match") == `"`+attachment.Hash+`"` {
+		w.WriteHeader(304)
+		return
+	}
+
+	data, err := api.store.AttachmentData(r.Context(), attachment)
+	if err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	// Only images are shown inline, other files are always downloaded
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.ContentType, "image/") && !strings.HasPrefix(attachment.ContentType, "image/svg") {
+		disposition = "inline"
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name}))
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (api *thoughts) detach(w http.ResponseWriter, r *http.Request) {
+	attachment := r.Context().Value(contextKeyAttachment).(*storage.Attachment)
+
+	if err := api.store.AttachmentDelete(r.Context(), attachment); err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	jsonResponse(w, 204, nil)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nrocco/bookmarks/storage"
+)
+
+func TestThoughtPreconditions(t *testing.T) {
+	store := newTestStore(t)
+
+	thought := storage.Thought{Content: "first"}
+	if err := store.ThoughtPersist(context.Background(), &thought); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := thoughts{store}.Routes()
+
+	put := func(ifMatch, content string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("PUT", "/"+thought.ID, strings.NewReader(content))
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	if w := put("", "second"); w.Code != 428 {
+		t.Fatalf("Expected 428 without If-Match but got %d", w.Code)
+	}
+
+	if w := put(`"2"`, "second"); w.Code != 412 {
+		t.Fatalf("Expected 412 for a stale If-Match but got %d", w.Code)
+	}
+
+	w := put(`"1"`, "second")
+	if w.Code != 200 || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("Expected 200 with a new etag but got %d %s", w.Code, w.Header().Get("ETag"))
+	}
+
+	r := httptest.NewRequest("GET", "/"+thought.ID, nil)
+	r.Header.Set("If-None-Match", `"2"`)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != 304 {
+		t.Fatalf("Expected 304 for the current etag but got %d", w.Code)
+	}
+}
+
+func TestBookmarkPreconditions(t *testing.T) {
+	store := newTestStore(t)
+
+	bookmark := storage.Bookmark{URL: "https://example.com/", Title: "Example"}
+	if err := store.BookmarkPersist(context.Background(), &bookmark); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := bookmarks{store}.Routes()
+
+	patch := func(ifMatch, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("PATCH", "/"+bookmark.ID, strings.NewReader(body))
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	if w := patch("", `{"Title":"Changed"}`); w.Code != 428 {
+		t.Fatalf("Expected 428 without If-Match but got %d", w.Code)
+	}
+
+	if w := patch(`"5"`, `{"Title":"Changed"}`); w.Code != 412 {
+		t.Fatalf("Expected 412 for a stale If-Match but got %d", w.Code)
+	}
+
+	// The version in the body can not be used to skip the check
+	w := patch(`"1"`, `{"Title":"Changed","Version":0}`)
+	if w.Code != 200 || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("Expected 200 with a new etag but got %d %s", w.Code, w.Header().Get("ETag"))
+	}
+
+	updated := storage.Bookmark{}
+	if err := json.NewDecoder(w.Body).Decode(&updated); err != nil || updated.Title != "Changed" || updated.Version != 2 {
+		t.Fatalf("Expected the changed bookmark at version 2 but got %+v: %v", updated, err)
+	}
+
+	r := httptest.NewRequest("GET", "/"+bookmark.ID, nil)
+	r.Header.Set("If-None-Match", `"2"`)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != 304 {
+		t.Fatalf("Expected 304 for the current etag but got %d", w.Code)
+	}
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/nrocco/bookmarks/storage"
+)
+
+var (
+	contextKeyWebhook = contextKey("webhook")
+)
+
+// webhookSecretMask replaces the secret of a webhook in responses
+const webhookSecretMask = "********"
+
+type webhooks struct {
+	store *storage.Store
+}
+
+// webhookRequest is the body of a create or update request, fields that are left out are not changed
+type webhookRequest struct {
+	URL    *string
+	Secret *string
+	Events *storage.Tags
+	Tags   *storage.Tags
+	Active *bool
+}
+
+func (api webhooks) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Get("/", api.list)
+	r.Post("/", api.create)
+	r.Route("/{id}", func(r chi.Router) {
+		r.Use(api.middleware)
+		r.Get("/", api.get)
+		r.Patch("/", api.update)
+		r.Delete("/", api.delete)
+		r.Get("/deliveries", api.deliveries)
+	})
+
+	return r
+}
+
+func (api *webhooks) list(w http.ResponseWriter, r *http.Request) {
+	webhooks := []*storage.Webhook{}
+	for _, webhook := range *api.store.WebhookList(r.Context()) {
+		webhooks = append(webhooks, maskSecret(webhook))
+	}
+
+	jsonResponse(w, 200, webhooks)
+}
+
+func (api *webhooks) create(w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), contextKeyWebhook, &storage.Webhook{Active: true})
+	api.update(w, r.WithContext(ctx))
+}
+
+func (api *webhooks) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webhook := storage.Webhook{ID: chi.URLParam(r, "id")}
+
+		if err := api.store.WebhookGet(r.Context(), &webhook); err != nil {
+			jsonError(w, "Webhook Not Found", 404)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyWebhook, &webhook)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (api *webhooks) get(w http.ResponseWriter, r *http.Request) {
+	webhook := r.Context().Value(contextKeyWebhook).(*storage.Webhook)
+
+	jsonResponse(w, 200, maskSecret(webhook))
+}
+
+func (api *webhooks) update(w http.ResponseWriter, r *http.Request) {
+	webhook := r.Context().Value(contextKeyWebhook).(*storage.Webhook)
+	created := webhook.ID == ""
+
+	var request webhookRequest
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	if err := decoder.Decode(&request); err != nil {
+		jsonError(w, err.Error(), 400)
+		return
+	}
+
+	if request.URL != nil {
+		webhook.URL = *request.URL
+	}
+
+	// A masked secret that is sent back does not replace the secret
+	if request.Secret != nil && *request.Secret != webhookSecretMask {
+		webhook.Secret = *request.Secret
+	}
+
+	if request.Events != nil {
+		webhook.Events = *request.Events
+	}
+
+	if request.Tags != nil {
+		webhook.Tags = *request.Tags
+	}
+
+	if request.Active != nil {
+		webhook.Active = *request.Active
+	}
+
+	if err := api.store.WebhookPersist(r.Context(), webhook); err == storage.ErrInvalidWebhook {
+		jsonError(w, err.Error(), 400)
+		return
+	} else if err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	// The secret is only shown once, when the webhook is created
+	if !created {
+		webhook = maskSecret(webhook)
+	}
+
+	jsonResponse(w, 200, webhook)
+}
+
+func (api *webhooks) delete(w http.ResponseWriter, r *http.Request) {
+	webhook := r.Context().Value(contextKeyWebhook).(*storage.Webhook)
+
+	if err := api.store.WebhookDelete(r.Context(), webhook); err != nil {
+		jsonError(w, err.Error(), 500)
+		return
+	}
+
+	jsonResponse(w, 204, nil)
+}
+
+func (api *webhooks) deliveries(w http.ResponseWriter, r *http.Request) {
+	webhook := r.Context().Value(contextKeyWebhook).(*storage.Webhook)
+
+	deliveries, totalCount := api.store.WebhookDeliveryList(r.Context(), webhook, &storage.WebhookDeliveryListOptions{
+		Status: r.URL.Query().Get("status"),
+		Limit:  asInt(r.URL.Query().Get("_limit"), 50),
+		Offset: asInt(r.URL.Query().Get("_offset"), 0),
+	})
+
+	w.Header().Set("X-Pagination-Total", strconv.Itoa(totalCount))
+
+	jsonResponse(w, 200, deliveries)
+}
+
+// maskSecret returns a copy of the webhook of which the secret is hidden
+func maskSecret(webhook *storage.Webhook) *storage.Webhook {
+	masked := *webhook
+	if masked.Secret != "" {
+		masked.Secret = webhookSecretMask
+	}
+
+	return &masked
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nrocco/bookmarks/storage"
+)
+
+func TestWebhookSecret(t *testing.T) {
+	handler := webhooks{newTestStore(t)}.Routes()
+
+	request := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+		return w
+	}
+
+	webhook := storage.Webhook{}
+	if w := request("POST", "/", `{"URL":"https://example.com/hook","Secret":"s3cret"}`); w.Code != 200 || json.NewDecoder(w.Body).Decode(&webhook) != nil || webhook.Secret != "s3cret" {
+		t.Fatalf("Expected the secret in the response of the create request but got %d %+v", w.Code, webhook)
+	}
+
+	for _, w := range []*httptest.ResponseRecorder{
+		request("GET", "/"+webhook.ID, ""),
+		request("PATCH", "/"+webhook.ID, `{"Active":false}`),
+	} {
+		if w.Code != 200 || strings.Contains(w.Body.String(), "s3cret") {
+			t.Fatalf("Expected the secret to be masked but got %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	if w := request("GET", "/", ""); w.Code != 200 || strings.Contains(w.Body.String(), "s3cret") || !strings.Contains(w.Body.String(), webhook.ID) {
+		t.Fatalf("Expected the listed secret to be masked but got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/nrocco/bookmarks/storage"
+	"github.com/rs/zerolog/hlog"
+)
+
+// websubMaxBodySize is the maximum size of content pushed by a hub
+const websubMaxBodySize = 10 * 1024 * 1024
+
+type websub struct {
+	store *storage.Store
+}
+
+func (api websub) Routes() chi.Router {
+	feeds := feeds{api.store}
+
+	r := chi.NewRouter()
+	r.Route("/{id}", func(r chi.Router) {
+		r.Use(feeds.middleware)
+		r.Get("/", api.verify)
+		r.Post("/", api.push)
+	})
+
+	return r
+}
+
+func (api *websub) verify(w http.ResponseWriter, r *http.Request) {
+	feed := r.Context().Value(contextKeyFeed).(*storage.Feed)
+	query := r.URL.Query()
+
+	if err := api.store.FeedVerifySubscription(r.Context(), feed, query.Get("hub.mode"), query.Get("hub.topic"), asInt(query.Get("hub.lease_seconds"), 0)); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Str("id", feed.ID).Msg("Error verifying hub subscription")
+		w.WriteHeader(404)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write([]byte(query.Get("hub.challenge")))
+}
+
+func (api *websub) push(w http.ResponseWriter, r *http.Request) {
+	feed := r.Context().Value(contextKeyFeed).(*storage.Feed)
+
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, websubMaxBodySize))
+	if err != nil {
+		w.WriteHeader(413)
+		return
+	}
+
+	// A hub expects a 2xx response even if the signature is invalid, so it does not learn about the failure
+	if err := api.store.FeedPush(r.Context(), feed, r.Header.Get("X-Hub-Signature"), body); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Str("id", feed.ID).Msg("Error verifying content pushed by hub")
+	}
+
+	w.WriteHeader(202)
+}
@@ -2,8 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"time"
 
+	"github.com/nrocco/bookmarks/storage"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -22,6 +25,35 @@ var rootCmd = &cobra.Command{
 			zerolog.SetGlobalLevel(zerolog.InfoLevel)
 		}
 
+		options := storage.FetcherOptions{
+			Timeout:            viper.GetDuration("fetch-timeout"),
+			UserAgent:          viper.GetString("fetch-user-agent"),
+			MaxBodySize:        viper.GetInt64("fetch-max-body-size"),
+			InsecureSkipVerify: viper.GetBool("fetch-insecure-skip-verify"),
+			HostInterval:       viper.GetDuration("fetch-host-interval"),
+			HostConcurrency:    viper.GetInt("fetch-host-concurrency"),
+			RespectRobots:      viper.GetBool("fetch-respect-robots"),
+		}
+
+		if proxy := viper.GetString("fetch-proxy"); proxy != "" {
+			proxyURL, err := url.Parse(proxy)
+			if err != nil {
+				return err
+			}
+			options.Proxy = proxyURL
+		}
+
+		storage.DefaultFetcher = storage.NewFetcher(options)
+
+		var rules []storage.SelectorRule
+		if err := viper.UnmarshalKey("extractors", &rules); err != nil {
+			return err
+		}
+
+		if len(rules) > 0 {
+			storage.RegisterExtractor(&storage.SelectorExtractor{Rules: rules})
+		}
+
 		return nil
 	},
 }
@@ -42,8 +74,25 @@ func init() {
 	rootCmd.PersistentFlags().BoolP("debug", "d", false, "Enable debug mode")
 	rootCmd.PersistentFlags().StringP("storage", "s", "data.db", "The location where to store state")
 
+	rootCmd.PersistentFlags().Duration("fetch-timeout", 10*time.Second, "Timeout for fetching bookmarks and feeds")
+	rootCmd.PersistentFlags().String("fetch-proxy", "", "Proxy url to use for fetching bookmarks and feeds (defaults to the HTTP_PROXY environment variables)")
+	rootCmd.PersistentFlags().String("fetch-user-agent", "", "User agent to use for fetching bookmarks and feeds")
+	rootCmd.PersistentFlags().Int64("fetch-max-body-size", 10*1024*1024, "Maximum size in bytes of a fetched bookmark or feed (0 for unlimited)")
+	rootCmd.PersistentFlags().Bool("fetch-insecure-skip-verify", false, "Do not verify tls certificates when fetching bookmarks and feeds")
+	rootCmd.PersistentFlags().Duration("fetch-host-interval", 500*time.Millisecond, "Minimum time between two requests to the same host")
+	rootCmd.PersistentFlags().Int("fetch-host-concurrency", 2, "Maximum number of concurrent requests to the same host (0 for unlimited)")
+	rootCmd.PersistentFlags().Bool("fetch-respect-robots", false, "Do not fetch urls that are disallowed by the robots.txt of the host")
+
 	viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
 	viper.BindPFlag("storage", rootCmd.PersistentFlags().Lookup("storage"))
+	viper.BindPFlag("fetch-timeout", rootCmd.PersistentFlags().Lookup("fetch-timeout"))
+	viper.BindPFlag("fetch-proxy", rootCmd.PersistentFlags().Lookup("fetch-proxy"))
+	viper.BindPFlag("fetch-user-agent", rootCmd.PersistentFlags().Lookup("fetch-user-agent"))
+	viper.BindPFlag("fetch-max-body-size", rootCmd.PersistentFlags().Lookup("fetch-max-body-size"))
+	viper.BindPFlag("fetch-insecure-skip-verify", rootCmd.PersistentFlags().Lookup("fetch-insecure-skip-verify"))
+	viper.BindPFlag("fetch-host-interval", rootCmd.PersistentFlags().Lookup("fetch-host-interval"))
+	viper.BindPFlag("fetch-host-concurrency", rootCmd.PersistentFlags().Lookup("fetch-host-concurrency"))
+	viper.BindPFlag("fetch-respect-robots", rootCmd.PersistentFlags().Lookup("fetch-respect-robots"))
 }
 
 func initConfig() {
@@ -3,8 +3,11 @@ package cmd
 import (
 	"context"
 	"os"
+	"time"
 
 	"github.com/nrocco/bookmarks/api"
+	"github.com/nrocco/bookmarks/mirror"
+	"github.com/nrocco/bookmarks/notify"
 	"github.com/nrocco/bookmarks/scheduler"
 	"github.com/nrocco/bookmarks/storage"
 	"github.com/rs/zerolog/log"
@@ -32,8 +35,25 @@ var serverCmd = &cobra.Command{
 		}
 		logger.Info().Str("storage", viper.GetString("storage")).Msg("Store ready")
 
+		store.Secret = viper.GetString("secret")
+		if count := store.FeedCredentialsCount(context.Background()); store.Secret == "" && count > 0 {
+			logger.Warn().Int("feeds", count).Msg("Feeds have encrypted credentials but no secret is configured, they are fetched without credentials")
+		}
+		store.CallbackURL = viper.GetString("public-url")
+		if store.CallbackURL == "" {
+			logger.Info().Msg("WebSub subscriptions are disabled")
+		}
+
+		store.ImageMaxSize = viper.GetInt64("image-max-size")
+		store.ImageMaxDimension = viper.GetInt("image-max-dimension")
+		store.ImageMaxAge = viper.GetDuration("image-max-age")
+		store.RevisionLimit = viper.GetInt("revision-limit")
+		store.JournalTemplate = viper.GetString("journal-template")
+		store.AttachmentMaxSize = viper.GetInt64("attachment-max-size")
+		store.AttachmentQuota = viper.GetInt64("attachment-quota")
+
 		// Setup the http server
-		api := api.New(logger, store, viper.GetString("username"), viper.GetString("password"))
+		api := api.New(logger, store, viper.GetString("username"), viper.GetString("password"), viper.GetString("feed-secret"))
 		logger.Info().Str("address", "http://"+viper.GetString("listen")).Msg("API ready")
 
 		if viper.GetInt("interval") != 0 {
@@ -42,6 +62,39 @@ var serverCmd = &cobra.Command{
 			logger.Info().Msg("Scheduler is disabled")
 		}
 
+		notifiers := notify.Notifiers{}
+		if url := viper.GetString("notify-webhook"); url != "" {
+			notifiers = append(notifiers, &notify.Webhook{URL: url})
+		}
+		if command := viper.GetString("notify-command"); command != "" {
+			notifiers = append(notifiers, &notify.Command{Command: command})
+		}
+		if addr := viper.GetString("smtp-addr"); addr != "" {
+			notifiers = append(notifiers, &notify.SMTP{
+				Addr:     addr,
+				Username: viper.GetString("smtp-username"),
+				Password: viper.GetString("smtp-password"),
+				From:     viper.GetString("smtp-from"),
+				To:       viper.GetStringSlice("smtp-to"),
+			})
+		}
+
+		if len(notifiers) > 0 {
+			scheduler.Reminders(store, notifiers)
+		} else {
+			logger.Info().Msg("Reminder notifications are disabled")
+		}
+
+		scheduler.Webhooks(store)
+
+		if dir := viper.GetString("sync-dir"); dir != "" {
+			mirror, err := mirror.New(store, dir)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("Could not open the markdown directory")
+			}
+			mirror.Watch(viper.GetDuration("sync-interval"))
+		}
+
 		// Run the http server
 		if err := api.ListenAndServe(viper.GetString("listen")); err != nil {
 			logger.Warn().Err(err).Msg("Stopped the api server")
@@ -57,11 +110,49 @@ func init() {
 	serverCmd.PersistentFlags().IntP("interval", "i", 15, "Fetch new feeds with this interval in minutes (0 to disable)")
 	serverCmd.PersistentFlags().StringP("username", "u", "", "Username for authentication")
 	serverCmd.PersistentFlags().StringP("password", "p", "", "Password for authentication")
+	serverCmd.PersistentFlags().String("secret", "", "Secret used to encrypt feed credentials in the database")
+	serverCmd.PersistentFlags().String("public-url", "", "Public url of this server, used as callback for WebSub hubs (empty to disable)")
+	serverCmd.PersistentFlags().String("feed-secret", "", "Secret to generate the tokens of the outbound feeds (defaults to the password)")
+	serverCmd.PersistentFlags().Int64("image-max-size", 5*1024*1024, "Maximum size in bytes of images downloaded to the image cache")
+	serverCmd.PersistentFlags().Int("image-max-dimension", 640, "Maximum width or height in pixels of cached images")
+	serverCmd.PersistentFlags().Duration("image-max-age", 30*24*time.Hour, "Remove cached images that have not been used for this long")
+	serverCmd.PersistentFlags().Int("revision-limit", 50, "Number of revisions to keep per thought (0 to keep all revisions)")
+	serverCmd.PersistentFlags().String("journal-template", "", "Go template for new journal thoughts, the day is available as {{ .Date }}")
+	serverCmd.PersistentFlags().Int64("attachment-max-size", 25*1024*1024, "Maximum size in bytes of a single thought attachment (0 for unlimited)")
+	serverCmd.PersistentFlags().Int64("attachment-quota", 1024*1024*1024, "Maximum total size in bytes of all thought attachments (0 for unlimited)")
+	serverCmd.PersistentFlags().String("sync-dir", "", "Directory to synchronize thoughts with as markdown files (empty to disable)")
+	serverCmd.PersistentFlags().Duration("sync-interval", 10*time.Second, "Interval to check the markdown directory for changes")
+	serverCmd.PersistentFlags().String("notify-webhook", "", "Url to post due reminders to as json")
+	serverCmd.PersistentFlags().String("notify-command", "", "Shell command to run for due reminders, which get the reminder as json on stdin")
+	serverCmd.PersistentFlags().String("smtp-addr", "", "Address (host:port) of the smtp server to mail due reminders with")
+	serverCmd.PersistentFlags().String("smtp-username", "", "Username for the smtp server")
+	serverCmd.PersistentFlags().String("smtp-password", "", "Password for the smtp server")
+	serverCmd.PersistentFlags().String("smtp-from", "bookmarks@localhost", "Sender address of reminder emails")
+	serverCmd.PersistentFlags().StringSlice("smtp-to", []string{}, "Recipient addresses of reminder emails")
 
 	viper.BindPFlag("listen", serverCmd.PersistentFlags().Lookup("listen"))
 	viper.BindPFlag("interval", serverCmd.PersistentFlags().Lookup("interval"))
 	viper.BindPFlag("username", serverCmd.PersistentFlags().Lookup("username"))
 	viper.BindPFlag("password", serverCmd.PersistentFlags().Lookup("password"))
+	viper.BindPFlag("secret", serverCmd.PersistentFlags().Lookup("secret"))
+	viper.BindPFlag("public-url", serverCmd.PersistentFlags().Lookup("public-url"))
+	viper.BindPFlag("feed-secret", serverCmd.PersistentFlags().Lookup("feed-secret"))
+	viper.BindPFlag("image-max-size", serverCmd.PersistentFlags().Lookup("image-max-size"))
+	viper.BindPFlag("image-max-dimension", serverCmd.PersistentFlags().Lookup("image-max-dimension"))
+	viper.BindPFlag("image-max-age", serverCmd.PersistentFlags().Lookup("image-max-age"))
+	viper.BindPFlag("revision-limit", serverCmd.PersistentFlags().Lookup("revision-limit"))
+	viper.BindPFlag("journal-template", serverCmd.PersistentFlags().Lookup("journal-template"))
+	viper.BindPFlag("attachment-max-size", serverCmd.PersistentFlags().Lookup("attachment-max-size"))
+	viper.BindPFlag("attachment-quota", serverCmd.PersistentFlags().Lookup("attachment-quota"))
+	viper.BindPFlag("sync-dir", serverCmd.PersistentFlags().Lookup("sync-dir"))
+	viper.BindPFlag("sync-interval", serverCmd.PersistentFlags().Lookup("sync-interval"))
+	viper.BindPFlag("notify-webhook", serverCmd.PersistentFlags().Lookup("notify-webhook"))
+	viper.BindPFlag("notify-command", serverCmd.PersistentFlags().Lookup("notify-command"))
+	viper.BindPFlag("smtp-addr", serverCmd.PersistentFlags().Lookup("smtp-addr"))
+	viper.BindPFlag("smtp-username", serverCmd.PersistentFlags().Lookup("smtp-username"))
+	viper.BindPFlag("smtp-password", serverCmd.PersistentFlags().Lookup("smtp-password"))
+	viper.BindPFlag("smtp-from", serverCmd.PersistentFlags().Lookup("smtp-from"))
+	viper.BindPFlag("smtp-to", serverCmd.PersistentFlags().Lookup("smtp-to"))
 
 	rootCmd.AddCommand(serverCmd)
 }
@@ -50,6 +50,7 @@ var testFetchFeedCmd = &cobra.Command{
 		fmt.Printf("  Title: %s\n", feed.Title)
 		fmt.Printf("  URL: %s\n", feed.URL)
 		fmt.Printf("  Etag: %s\n", feed.Etag)
+		fmt.Printf("  Image: %s\n", feed.Image)
 		fmt.Printf("  Refreshed: %v\n", feed.Refreshed)
 		fmt.Printf("  LastAuthored: %v\n", feed.LastAuthored)
 		fmt.Printf("  Items:\n")
@@ -58,6 +59,10 @@ var testFetchFeedCmd = &cobra.Command{
 			fmt.Printf("  - Title: %s\n", item.Title)
 			fmt.Printf("    URL: %s\n", item.URL)
 			fmt.Printf("    Date: %v\n", item.Date)
+
+			for _, enclosure := range item.Enclosures {
+				fmt.Printf("    Enclosure: %s (%s, %d seconds)\n", enclosure.URL, enclosure.Type, enclosure.Duration)
+			}
 		}
 
 		return nil
@@ -1,10 +1,11 @@
 module github.com/nrocco/bookmarks
 
 require (
-	github.com/PuerkitoBio/goquery v1.7.1 // indirect
+	github.com/PuerkitoBio/goquery v1.7.1
 	github.com/go-chi/chi v4.1.2+incompatible
 	github.com/go-shiori/go-readability v0.0.0-20210627123243-82cc33435520
 	github.com/kr/pretty v0.2.0 // indirect
+	github.com/ledongthuc/pdf v0.0.0-20240201131950-da5b75280b06
 	github.com/microcosm-cc/bluemonday v1.0.15
 	github.com/mmcdole/gofeed v1.1.3
 	github.com/mmcdole/goxpp v0.0.0-20200921145534-2f3784f67354 // indirect
@@ -14,8 +15,10 @@ require (
 	github.com/rs/zerolog v1.23.0
 	github.com/spf13/cobra v1.2.1
 	github.com/spf13/viper v1.8.1
+	github.com/yuin/goldmark v1.4.11
 	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
 	golang.org/x/tools v0.1.4 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 	modernc.org/ccgo/v3 v3.9.6 // indirect
 	modernc.org/memory v1.0.5 // indirect
 	modernc.org/sqlite v1.11.2
@@ -0,0 +1,107 @@
+package mirror
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nrocco/bookmarks/storage"
+	"gopkg.in/yaml.v2"
+)
+
+// frontMatter is the yaml header of a markdown file
+type frontMatter struct {
+	ID      string    `yaml:"id,omitempty"`
+	Tags    []string  `yaml:"tags"`
+	Created time.Time `yaml:"created,omitempty"`
+}
+
+// file is a markdown file in the mirrored directory
+type file struct {
+	Name    string
+	Hash    string
+	Thought storage.Thought
+}
+
+// hash returns the sha256 of the contents of a file
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// format renders the thought as markdown with a yaml front matter
+func format(thought *storage.Thought) ([]byte, error) {
+	tags := []string(thought.Tags)
+	if tags == nil {
+		tags = []string{}
+	}
+
+	header, err := yaml.Marshal(frontMatter{thought.ID, tags, thought.Created})
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("---\n")
+	buffer.Write(header)
+	buffer.WriteString("---\n")
+	buffer.WriteString(thought.Content)
+
+	return buffer.Bytes(), nil
+}
+
+// parse reads a markdown file with an optional yaml front matter
+func parse(name string, data []byte) (*file, error) {
+	parsed := file{Name: name, Hash: hash(data)}
+	content := string(data)
+
+	if strings.HasPrefix(content, "---\n") {
+		end := strings.Index(content[3:], "\n---\n")
+		if end != -1 {
+			var header frontMatter
+			if err := yaml.Unmarshal([]byte(content[4:end+4]), &header); err != nil {
+				return nil, err
+			}
+
+			parsed.Thought.ID = header.ID
+			parsed.Thought.Tags = header.Tags
+			parsed.Thought.Created = header.Created
+			content = content[end+8:]
+		}
+	}
+
+	parsed.Thought.Content = content
+
+	return &parsed, nil
+}
+
+// equal returns true if the file has the same content and tags as the thought
+func (f *file) equal(thought *storage.Thought) bool {
+	return f.Thought.Content == thought.Content && strings.Join(f.Thought.Tags, ",") == strings.Join(thought.Tags, ",")
+}
+
+// slug turns the title of a thought into a file name
+func slug(title string) string {
+	var builder strings.Builder
+
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			builder.WriteRune(r)
+			dash = false
+		} else if !dash && builder.Len() > 0 {
+			builder.WriteRune('-')
+			dash = true
+		}
+
+		if builder.Len() >= 60 {
+			break
+		}
+	}
+
+	return strings.Trim(builder.String(), "-")
+}
@@ -0,0 +1,390 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nrocco/bookmarks/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// stateFile keeps track of the files and thoughts at the last synchronization
+	stateFile = ".mirror.json"
+
+	// ArchivedTag is added to a thought whose file is removed, archived
+	// thoughts are not written to the directory
+	ArchivedTag = "archived"
+
+	// missingScans is the number of synchronizations a file has to be missing
+	// before its thought is archived, so a file that is briefly gone while it
+	// is saved or moved does not affect the thought
+	missingScans = 3
+)
+
+// Mirror keeps a directory of markdown files in sync with the thoughts in the store
+type Mirror struct {
+	store *storage.Store
+	dir   string
+	state map[string]*entry
+}
+
+// entry is the state of a thought and its file after the last synchronization,
+// a thought has changed if its Updated timestamp differs and a file has
+// changed if its hash differs. Missing counts the synchronizations since the
+// file was last seen.
+type entry struct {
+	File    string
+	Hash    string
+	Updated time.Time
+	Missing int `json:",omitempty"`
+}
+
+// New returns a Mirror of the thoughts in store to the markdown files in dir
+func New(store *storage.Store, dir string) (*Mirror, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	mirror := Mirror{
+		store: store,
+		dir:   dir,
+		state: map[string]*entry{},
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, stateFile))
+	if err == nil {
+		if err := json.Unmarshal(data, &mirror.state); err != nil {
+			return nil, err
+		}
+
+		// The state file is not trusted to point outside of the directory
+		for id, state := range mirror.state {
+			if state == nil || !validFileName(state.File) {
+				log.Warn().Str("id", id).Msg("Ignoring the state of a thought with an invalid file name")
+				delete(mirror.state, id)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return &mirror, nil
+}
+
+// Watch synchronizes the directory right away and then every interval
+func (mirror *Mirror) Watch(interval time.Duration) {
+	log.Info().Str("dir", mirror.dir).Dur("interval", interval).Msg("Starting the markdown mirror")
+
+	go func() {
+		ticker := time.NewTicker(interval)
+
+		for {
+			if err := mirror.Sync(log.Logger.WithContext(context.TODO())); err != nil {
+				log.Warn().Err(err).Str("dir", mirror.dir).Msg("Error synchronizing markdown files")
+			}
+
+			<-ticker.C
+		}
+	}()
+}
+
+// Sync writes thoughts that changed in the store to their file and imports
+// files that changed on disk. If both changed the thought in the store wins
+// and the edited file is kept as a conflict copy next to it.
+func (mirror *Mirror) Sync(ctx context.Context) error {
+	// Without the thoughts every mirrored file would be treated as a deleted thought
+	thoughts, err := mirror.store.ThoughtListAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	files, others, err := mirror.scan()
+	if err != nil {
+		return err
+	}
+
+	// An empty directory is more likely an unmounted volume than a request to delete all thoughts
+	if len(files) == 0 && len(others) == 0 && len(mirror.state) > 0 {
+		log.Ctx(ctx).Warn().Str("dir", mirror.dir).Msg("Directory is empty, skipping synchronization")
+		return nil
+	}
+
+	exists := map[string]bool{}
+
+	for _, thought := range *thoughts {
+		exists[thought.ID] = true
+
+		if archived(thought) {
+			continue
+		}
+
+		if err := mirror.syncThought(ctx, thought, files[thought.ID]); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("id", thought.ID).Msg("Error synchronizing thought")
+		}
+	}
+
+	for id, state := range mirror.state {
+		if exists[id] {
+			continue
+		}
+
+		delete(mirror.state, id)
+
+		if f := files[id]; f != nil && f.Hash == state.Hash {
+			os.Remove(filepath.Join(mirror.dir, f.Name))
+			log.Ctx(ctx).Info().Str("id", id).Str("file", f.Name).Msg("Removed file of deleted thought")
+		} else if f != nil {
+			mirror.conflict(ctx, f)
+		}
+
+		delete(files, id)
+	}
+
+	for id, f := range files {
+		if !exists[id] {
+			others = append(others, f)
+		}
+	}
+
+	for _, f := range others {
+		if err := mirror.importFile(ctx, f); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("file", f.Name).Msg("Error importing file")
+		}
+	}
+
+	return mirror.saveState()
+}
+
+// syncThought synchronizes a thought with its file, which is nil if there is no file
+func (mirror *Mirror) syncThought(ctx context.Context, thought *storage.Thought, f *file) error {
+	state := mirror.state[thought.ID]
+
+	if state == nil {
+		if f != nil && !f.equal(thought) {
+			mirror.conflict(ctx, f)
+		}
+
+		name := mirror.fileName(thought)
+		if f != nil {
+			name = f.Name
+		}
+
+		return mirror.write(ctx, thought, name)
+	}
+
+	if f == nil {
+		if !thought.Updated.Equal(state.Updated) {
+			return mirror.write(ctx, thought, state.File)
+		}
+
+		if state.Missing++; state.Missing < missingScans {
+			return nil
+		}
+
+		log.Ctx(ctx).Info().Str("id", thought.ID).Str("file", state.File).Msg("Archiving thought of removed file")
+
+		thought.Tags = append(thought.Tags, ArchivedTag)
+		if err := mirror.store.ThoughtPersist(ctx, thought); err != nil {
+			return err
+		}
+
+		delete(mirror.state, thought.ID)
+
+		return nil
+	}
+
+	// Renamed files are followed
+	state.File = f.Name
+	state.Missing = 0
+
+	storeChanged := !thought.Updated.Equal(state.Updated)
+	fileChanged := f.Hash != state.Hash
+
+	switch {
+	case !storeChanged && !fileChanged:
+		return nil
+	case storeChanged && !fileChanged:
+		return mirror.write(ctx, thought, f.Name)
+	case !storeChanged && fileChanged:
+		return mirror.importChanges(ctx, thought, f)
+	case f.equal(thought):
+		mirror.state[thought.ID] = &entry{f.Name, f.Hash, thought.Updated, 0}
+		return nil
+	default:
+		mirror.conflict(ctx, f)
+		return mirror.write(ctx, thought, f.Name)
+	}
+}
+
+// importChanges updates the thought with the content and tags of the file
+func (mirror *Mirror) importChanges(ctx context.Context, thought *storage.Thought, f *file) error {
+	thought.Content = f.Thought.Content
+	thought.Tags = f.Thought.Tags
+
+	// The version of the listed thought makes the update fail if it was changed in the meantime
+	if err := mirror.store.ThoughtPersist(ctx, thought); err != nil {
+		return err
+	}
+
+	// Reload the thought so the state has the updated timestamp as stored in the database
+	if err := mirror.store.ThoughtGet(ctx, thought); err != nil {
+		return err
+	}
+
+	mirror.state[thought.ID] = &entry{f.Name, f.Hash, thought.Updated, 0}
+
+	log.Ctx(ctx).Info().Str("id", thought.ID).Str("file", f.Name).Msg("Imported changes from file")
+
+	return nil
+}
+
+// importFile creates a new thought from a file that is not linked to a thought
+// and adds the id of the new thought to the front matter of the file
+func (mirror *Mirror) importFile(ctx context.Context, f *file) error {
+	thought := storage.Thought{
+		Created: f.Thought.Created,
+		Content: f.Thought.Content,
+		Tags:    f.Thought.Tags,
+	}
+
+	if err := mirror.store.ThoughtPersist(ctx, &thought); err != nil {
+		return err
+	}
+
+	if err := mirror.store.ThoughtGet(ctx, &thought); err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Str("id", thought.ID).Str("file", f.Name).Msg("Created thought from file")
+
+	return mirror.write(ctx, &thought, f.Name)
+}
+
+// write writes the thought to the file and records the state
+func (mirror *Mirror) write(ctx context.Context, thought *storage.Thought, name string) error {
+	data, err := format(thought)
+	if err != nil {
+		return err
+	}
+
+	// Write to a hidden temporary file first so editors never see a partial file
+	temporary := filepath.Join(mirror.dir, "."+name+".tmp")
+	if err := ioutil.WriteFile(temporary, data, 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temporary, filepath.Join(mirror.dir, name)); err != nil {
+		os.Remove(temporary)
+		return err
+	}
+
+	mirror.state[thought.ID] = &entry{name, hash(data), thought.Updated, 0}
+
+	log.Ctx(ctx).Debug().Str("id", thought.ID).Str("file", name).Msg("Wrote thought to file")
+
+	return nil
+}
+
+// conflict keeps a copy of an edited file that could not be imported
+func (mirror *Mirror) conflict(ctx context.Context, f *file) {
+	name := strings.TrimSuffix(f.Name, ".md") + ".conflict-" + time.Now().Format("20060102T150405") + ".md"
+
+	if err := os.Rename(filepath.Join(mirror.dir, f.Name), filepath.Join(mirror.dir, name)); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("file", f.Name).Msg("Error keeping conflicting file")
+		return
+	}
+
+	log.Ctx(ctx).Warn().Str("id", f.Thought.ID).Str("file", f.Name).Str("conflict", name).Msg("Conflicting changes, the edited file is kept as a copy")
+}
+
+// scan parses the markdown files in the directory, files with the id of a
+// thought are returned by id and the other files as a list
+func (mirror *Mirror) scan() (map[string]*file, []*file, error) {
+	entries, err := ioutil.ReadDir(mirror.dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := map[string]*file{}
+	others := []*file{}
+
+	for _, info := range entries {
+		name := info.Name()
+		if info.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".md") || strings.Contains(name, ".conflict-") {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(mirror.dir, name))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		f, err := parse(name, data)
+		if err != nil {
+			log.Warn().Err(err).Str("file", name).Msg("Skipping file with invalid front matter")
+			continue
+		}
+
+		// A copied file gets a thought of its own
+		if id := f.Thought.ID; id != "" && files[id] == nil && (mirror.state[id] == nil || mirror.state[id].File == name || !existsFile(mirror.dir, mirror.state[id].File)) {
+			files[id] = f
+		} else {
+			f.Thought.ID = ""
+			others = append(others, f)
+		}
+	}
+
+	return files, others, nil
+}
+
+// fileName returns an unused file name based on the title of the thought
+func (mirror *Mirror) fileName(thought *storage.Thought) string {
+	name := slug(thought.Title())
+	if name == "" {
+		name = thought.ID
+	}
+
+	if existsFile(mirror.dir, name+".md") {
+		name += "-" + thought.ID
+	}
+
+	return name + ".md"
+}
+
+// saveState stores the state of the last synchronization in the directory
+func (mirror *Mirror) saveState() error {
+	data, err := json.MarshalIndent(mirror.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(mirror.dir, stateFile), data, 0644)
+}
+
+// archived returns true if the thought has the ArchivedTag
+func archived(thought *storage.Thought) bool {
+	for _, tag := range thought.Tags {
+		if tag == ArchivedTag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validFileName returns true if name is a markdown file directly in the directory
+func validFileName(name string) bool {
+	return strings.HasSuffix(name, ".md") && !strings.ContainsAny(name, `/\`) && !strings.HasPrefix(name, ".")
+}
+
+func existsFile(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+
+	return err == nil
+}
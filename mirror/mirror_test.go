@@ -0,0 +1,230 @@
+package mirror
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nrocco/bookmarks/storage"
+)
+
+func TestParse(t *testing.T) {
+	thought := storage.Thought{ID: "abc", Content: "# Hello\n\n---\nworld\n", Tags: storage.Tags{"a", "b"}}
+
+	data, err := format(&thought)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := parse("hello.md", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Thought.ID != "abc" || !parsed.equal(&thought) {
+		t.Fatalf("Expected the thought to survive a round trip but got %+v", parsed.Thought)
+	}
+
+	parsed, _ = parse("plain.md", []byte("just text"))
+	if parsed.Thought.ID != "" || parsed.Thought.Content != "just text" {
+		t.Fatalf("Unexpected file without front matter %+v", parsed.Thought)
+	}
+
+	if name := slug("Hello, World: a title!"); name != "hello-world-a-title" {
+		t.Fatalf("Unexpected slug %q", name)
+	}
+}
+
+func TestSync(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	dir := filepath.Join(tmpDir, "notes")
+
+	store, err := storage.New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thought := storage.Thought{Content: "# Groceries\n\nmilk\n", Tags: storage.Tags{"home"}}
+	if err := store.ThoughtPersist(ctx, &thought); err != nil {
+		t.Fatal(err)
+	}
+
+	mirror, err := New(store, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mirror.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "groceries.md")
+	data, err := ioutil.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), "id: "+thought.ID) || !strings.HasSuffix(string(data), "milk\n") {
+		t.Fatalf("Expected the thought to be written to groceries.md but got %q: %v", data, err)
+	}
+
+	// Edits of the file are imported
+	ioutil.WriteFile(path, []byte(strings.Replace(string(data), "milk", "milk\neggs", 1)), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "new.md"), []byte("# New note\n"), 0644)
+
+	if err := mirror.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	store.ThoughtGet(ctx, &thought)
+	if !strings.Contains(thought.Content, "eggs") {
+		t.Fatalf("Expected the edit to be imported but got %q", thought.Content)
+	}
+
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "new.md")); !strings.Contains(string(data), "id: ") {
+		t.Fatalf("Expected the new file to get an id but got %q", data)
+	}
+
+	if _, total := store.ThoughtList(ctx, &storage.ThoughtListOptions{}); total != 2 {
+		t.Fatalf("Expected 2 thoughts but got %d", total)
+	}
+
+	// Changes in the store and the file conflict, the store wins
+	thought.Content = "# Groceries\n\nbread\n"
+	if err := store.ThoughtPersist(ctx, &thought); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ = ioutil.ReadFile(path)
+	ioutil.WriteFile(path, []byte(strings.Replace(string(data), "eggs", "cheese", 1)), 0644)
+
+	if err := mirror.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := ioutil.ReadFile(path); !strings.HasSuffix(string(data), "bread\n") {
+		t.Fatalf("Expected the thought to win the conflict but got %q", data)
+	}
+
+	conflicts, _ := filepath.Glob(filepath.Join(dir, "groceries.conflict-*.md"))
+	if len(conflicts) != 1 {
+		t.Fatalf("Expected a conflict copy but got %v", conflicts)
+	}
+
+	// A file that is missing for a single synchronization does not affect the thought
+	os.Remove(path)
+
+	if err := mirror.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	store.ThoughtGet(ctx, &thought)
+	if len(thought.Tags) != 1 {
+		t.Fatalf("Expected the thought to be left alone but got tags %v", thought.Tags)
+	}
+
+	// A file that stays removed archives the thought
+	for i := 1; i < missingScans; i++ {
+		if err := mirror.Sync(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.ThoughtGet(ctx, &thought); err != nil || !archived(&thought) {
+		t.Fatalf("Expected the thought of the removed file to be archived but got %v: %v", thought.Tags, err)
+	}
+
+	if err := mirror.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if existsFile(dir, "groceries.md") {
+		t.Fatal("Expected the archived thought not to be written")
+	}
+
+	// State is kept across restarts
+	mirror, err = New(store, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mirror.state) != 1 {
+		t.Fatalf("Expected the state of 1 file but got %d", len(mirror.state))
+	}
+}
+
+func TestStateFileNames(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	store, err := storage.New(context.Background(), filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(tmpDir, "notes")
+	os.MkdirAll(dir, 0755)
+	ioutil.WriteFile(filepath.Join(dir, stateFile), []byte(`{"a":{"File":"../evil.md"},"b":{"File":"sub/dir.md"},"c":{"File":"ok.md"}}`), 0644)
+
+	mirror, err := New(store, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mirror.state) != 1 || mirror.state["c"] == nil {
+		t.Fatalf("Expected only the state of ok.md but got %v", mirror.state)
+	}
+}
+
+func TestSyncStoreError(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	dir := filepath.Join(tmpDir, "notes")
+
+	store, err := storage.New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thought := storage.Thought{Content: "# Groceries\n\nmilk\n"}
+	if err := store.ThoughtPersist(ctx, &thought); err != nil {
+		t.Fatal(err)
+	}
+
+	mirror, err := New(store, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mirror.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The thoughts can not be read, which must not look like they were all deleted
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if err := mirror.Sync(cancelled); err == nil {
+		t.Fatal("Expected the synchronization to fail")
+	}
+
+	if !existsFile(dir, "groceries.md") || mirror.state[thought.ID] == nil {
+		t.Fatal("Expected the file of the thought to be kept")
+	}
+}
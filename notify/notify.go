@@ -0,0 +1,265 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/nrocco/bookmarks/storage"
+)
+
+// Notifier sends a reminder to the user
+type Notifier interface {
+	// Name identifies the notifier in storage.Reminder.Delivered
+	Name() string
+
+	Notify(ctx context.Context, reminder *storage.Reminder) error
+}
+
+// Notifiers sends a reminder through all notifiers, it fails if any of them fails
+type Notifiers []Notifier
+
+// Name returns the names of all notifiers
+func (notifiers Notifiers) Name() string {
+	names := []string{}
+	for _, notifier := range notifiers {
+		names = append(names, notifier.Name())
+	}
+
+	return strings.Join(names, ",")
+}
+
+// Notify sends the reminder through all notifiers that did not deliver it
+// yet and adds the ones that succeed to reminder.Delivered
+func (notifiers Notifiers) Notify(ctx context.Context, reminder *storage.Reminder) error {
+	errs := []string{}
+
+	for _, notifier := range notifiers {
+		if delivered(reminder, notifier.Name()) {
+			continue
+		}
+
+		if err := notifier.Notify(ctx, reminder); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			reminder.Delivered = append(reminder.Delivered, notifier.Name())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// delivered returns true if the notifier with the given name already sent the reminder
+func delivered(reminder *storage.Reminder, name string) bool {
+	for _, tag := range reminder.Delivered {
+		if tag == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Webhook posts the reminder as json to an url
+type Webhook struct {
+	URL string
+}
+
+// Name returns webhook
+func (webhook *Webhook) Name() string {
+	return "webhook"
+}
+
+// Notify posts the reminder to the webhook
+func (webhook *Webhook) Notify(ctx context.Context, reminder *storage.Reminder) error {
+	body, err := json.Marshal(reminder)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, "POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("Webhook returned %s", response.Status)
+	}
+
+	return nil
+}
+
+// Command runs a shell command with the reminder as json on stdin and its
+// fields in BOOKMARKS_REMINDER_* environment variables
+type Command struct {
+	Command string
+}
+
+// Name returns command
+func (command *Command) Name() string {
+	return "command"
+}
+
+// Notify runs the command for the reminder
+func (command *Command) Notify(ctx context.Context, reminder *storage.Reminder) error {
+	body, err := json.Marshal(reminder)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command.Command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"BOOKMARKS_REMINDER_ID="+reminder.ID,
+		"BOOKMARKS_REMINDER_TYPE="+reminder.Type,
+		"BOOKMARKS_REMINDER_TARGET_ID="+reminder.TargetID,
+		"BOOKMARKS_REMINDER_DUE="+reminder.Due.Format(time.RFC3339),
+		"BOOKMARKS_REMINDER_TITLE="+reminder.Title,
+		"BOOKMARKS_REMINDER_URL="+reminder.URL,
+		"BOOKMARKS_REMINDER_NOTE="+reminder.Note,
+	)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// SMTP sends the reminder as an email through an smtp server
+type SMTP struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Name returns smtp
+func (server *SMTP) Name() string {
+	return "smtp"
+}
+
+// Notify mails the reminder, like smtp.SendMail but it gives up when ctx is
+// done or the server does not answer within a minute
+func (server *SMTP) Notify(ctx context.Context, reminder *storage.Reminder) error {
+	host, _, err := net.SplitHostPort(server.Addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+
+	conn, err := dialer.DialContext(ctx, "tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+
+	// Closing the connection interrupts the client when ctx is done
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+
+	if server.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", server.Username, server.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(server.From); err != nil {
+		return err
+	}
+
+	for _, to := range server.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(Message(server.From, server.To, reminder)); err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// Message formats the reminder as an email
+func Message(from string, to []string, reminder *storage.Reminder) []byte {
+	var buffer bytes.Buffer
+
+	fmt.Fprintf(&buffer, "From: %s\r\n", from)
+	fmt.Fprintf(&buffer, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buffer, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Reminder: "+reminder.Title))
+	fmt.Fprintf(&buffer, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buffer, "Message-ID: <%s.%d@bookmarks>\r\n", reminder.ID, reminder.Attempts)
+	fmt.Fprintf(&buffer, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buffer, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&buffer, "Content-Transfer-Encoding: 8bit\r\n")
+	fmt.Fprintf(&buffer, "\r\n")
+
+	if reminder.Note != "" {
+		fmt.Fprintf(&buffer, "%s\r\n\r\n", strings.Replace(reminder.Note, "\n", "\r\n", -1))
+	}
+
+	fmt.Fprintf(&buffer, "%s\r\n", reminder.Title)
+	if reminder.URL != "" {
+		fmt.Fprintf(&buffer, "%s\r\n", reminder.URL)
+	}
+
+	return buffer.Bytes()
+}
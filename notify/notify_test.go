@@ -0,0 +1,127 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nrocco/bookmarks/storage"
+)
+
+func TestNotifiers(t *testing.T) {
+	reminder := &storage.Reminder{ID: "abc", Title: "Read this", URL: "https://example.com/", Note: "before friday"}
+
+	var received storage.Reminder
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	output := filepath.Join(tmpDir, "output")
+
+	notifiers := Notifiers{
+		&Webhook{URL: server.URL},
+		&Command{Command: "echo \"$BOOKMARKS_REMINDER_TITLE\" > " + output},
+	}
+
+	if err := notifiers.Notify(context.Background(), reminder); err != nil {
+		t.Fatal(err)
+	}
+
+	if received.ID != "abc" || received.Title != "Read this" {
+		t.Fatalf("Expected the reminder to be posted but got %+v", received)
+	}
+
+	if data, _ := ioutil.ReadFile(output); string(data) != "Read this\n" {
+		t.Fatalf("Expected the command to get the title but got %q", data)
+	}
+
+	if len(reminder.Delivered) != 2 || reminder.Delivered[0] != "webhook" || reminder.Delivered[1] != "command" {
+		t.Fatalf("Expected both notifiers to be delivered but got %v", reminder.Delivered)
+	}
+
+	failing := Notifiers{&Command{Command: "echo oops; exit 1"}, &Webhook{URL: server.URL + "/\x00"}}
+	if err := failing.Notify(context.Background(), &storage.Reminder{ID: "abc"}); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Fatalf("Expected the errors of all notifiers but got %v", err)
+	}
+}
+
+func TestNotifiersRetry(t *testing.T) {
+	posts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+	}))
+	defer server.Close()
+
+	reminder := &storage.Reminder{ID: "abc", Title: "Read this"}
+	notifiers := Notifiers{&Webhook{URL: server.URL}, &Command{Command: "exit 1"}}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		if err := notifiers.Notify(context.Background(), reminder); err == nil {
+			t.Fatal("Expected the command to fail")
+		}
+	}
+
+	if posts != 1 {
+		t.Fatalf("Expected the webhook to be sent once but got %d posts", posts)
+	}
+}
+
+func TestSMTPTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The server accepts the connection but never greets the client
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			ioutil.ReadAll(conn)
+			conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	server := SMTP{Addr: listener.Addr().String(), From: "bookmarks@localhost", To: []string{"me@localhost"}}
+
+	start := time.Now()
+	if err := server.Notify(ctx, &storage.Reminder{ID: "abc"}); err == nil {
+		t.Fatal("Expected an error from a server that does not answer")
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Expected Notify to give up when the context is done but it took %s", elapsed)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	message := string(Message("me@example.com", []string{"you@example.com"}, &storage.Reminder{ID: "abc", Title: "Café", URL: "https://example.com/", Note: "line 1\nline 2"}))
+
+	for _, expected := range []string{
+		"To: you@example.com\r\n",
+		"Subject: =?utf-8?q?Reminder:_Caf=C3=A9?=\r\n",
+		"\r\n\r\nline 1\r\nline 2\r\n\r\nCafé\r\nhttps://example.com/\r\n",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Fatalf("Expected %q in %q", expected, message)
+		}
+	}
+}
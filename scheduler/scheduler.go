@@ -4,11 +4,12 @@ import (
 	"context"
 	"time"
 
+	"github.com/nrocco/bookmarks/notify"
 	"github.com/nrocco/bookmarks/storage"
 	"github.com/rs/zerolog/log"
 )
 
-// New starts a new scheduler that refreshes rrs/atom feeds
+// New starts a new scheduler that refreshes rrs/atom feeds and cleans up the image cache
 func New(store *storage.Store, interval int) {
 	log.Info().Int("interval", interval).Msg("Starting the scheduler")
 
@@ -21,6 +22,8 @@ func New(store *storage.Store, interval int) {
 
 				feeds, totalCount := store.FeedList(context.TODO(), &storage.FeedListOptions{
 					NotRefreshedSince: notRefreshedSince,
+					NotSubscribed:     true,
+					Active:            true,
 					Limit:             100,
 				})
 
@@ -32,6 +35,87 @@ func New(store *storage.Store, interval int) {
 					}
 				}
 			}()
+
+			go store.ImageCleanup(log.Logger.WithContext(context.TODO()))
+		}
+	}()
+}
+
+// Reminders starts a scheduler that sends due reminders through the notifier every minute
+func Reminders(store *storage.Store, notifier notify.Notifier) {
+	log.Info().Msg("Starting the reminder scheduler")
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+
+		for range ticker.C {
+			ctx := log.Logger.WithContext(context.TODO())
+
+			for _, reminder := range store.ReminderDue(ctx, time.Now()) {
+				err := notifier.Notify(ctx, reminder)
+				if err != nil {
+					log.Warn().Err(err).Str("id", reminder.ID).Int("attempt", reminder.Attempts+1).Msg("Error sending reminder")
+				} else {
+					log.Info().Str("id", reminder.ID).Str("title", reminder.Title).Msg("Sent reminder")
+				}
+
+				if err := store.ReminderDelivered(ctx, reminder, err); err != nil {
+					log.Warn().Err(err).Str("id", reminder.ID).Msg("Error updating the status of reminder")
+				}
+			}
+		}
+	}()
+}
+
+// Webhooks starts a scheduler that queues a delivery of every new event for
+// the matching webhooks and delivers them, failed deliveries are retried when
+// their next attempt is due
+func Webhooks(store *storage.Store) {
+	log.Info().Msg("Starting the webhook scheduler")
+
+	go func() {
+		ctx := log.Logger.WithContext(context.TODO())
+
+		// Subscribe before looking up the latest event so no event is missed
+		events, _ := store.Subscribe()
+		latest := store.EventLatest(ctx)
+
+		ticker := time.NewTicker(10 * time.Second)
+
+		for {
+			select {
+			case event, ok := <-events:
+				if ok {
+					if event.ID > latest {
+						store.WebhookEnqueue(ctx, event)
+						latest = event.ID
+					}
+					break
+				}
+
+				// The subscription is closed if delivering took too long, catch up with the stored events
+				log.Warn().Int64("after", latest).Msg("Webhook scheduler fell behind, catching up")
+
+				events, _ = store.Subscribe()
+				missed, err := store.EventList(ctx, latest, -1)
+				if err != nil {
+					log.Warn().Err(err).Msg("Error fetching missed events")
+				}
+
+				for _, event := range missed {
+					store.WebhookEnqueue(ctx, event)
+					latest = event.ID
+				}
+			case <-ticker.C:
+			}
+
+			for _, delivery := range store.WebhookDue(ctx, time.Now()) {
+				if err := store.WebhookDeliver(ctx, delivery); err != nil {
+					log.Warn().Err(err).Int64("id", delivery.ID).Str("webhook", delivery.WebhookID).Int("attempt", delivery.Attempts).Msg("Error delivering webhook")
+				} else {
+					log.Info().Int64("id", delivery.ID).Str("webhook", delivery.WebhookID).Str("event", delivery.EventType).Msg("Delivered webhook")
+				}
+			}
 		}
 	}()
 }
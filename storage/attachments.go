@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrNoAttachmentKey is returned if the Attachment does not have a ThoughtID or Name
+	ErrNoAttachmentKey = errors.New("Missing Attachment.ThoughtID or Attachment.Name")
+
+	// ErrAttachmentTooLarge is returned if an attachment is larger than Store.AttachmentMaxSize
+	ErrAttachmentTooLarge = errors.New("Attachment is too large")
+
+	// ErrAttachmentQuota is returned if storing an attachment would exceed Store.AttachmentQuota
+	ErrAttachmentQuota = errors.New("Attachment quota exceeded")
+)
+
+// attachmentScheme is the url scheme used in markdown to refer to an attachment of the thought
+const attachmentScheme = "attachment:"
+
+// Attachment is a file attached to a thought, the contents are stored once
+// per unique sha256 hash no matter how many thoughts they are attached to
+type Attachment struct {
+	ThoughtID   string
+	Name        string
+	Hash        string
+	ContentType string
+	Size        int64
+	Created     time.Time
+}
+
+// AttachmentName returns the base name of a file name, without any directories
+func AttachmentName(name string) string {
+	name = path.Base(strings.Replace(name, "\\", "/", -1))
+	if name == "." || name == "/" {
+		return ""
+	}
+
+	return name
+}
+
+// AttachmentURL returns the api path of the attachment with the given name
+func AttachmentURL(thoughtID, name string) string {
+	return "/api/thoughts/" + thoughtID + "/attachments/" + url.PathEscape(name)
+}
+
+// AttachmentList lists the attachments of a thought by name
+func (store *Store) AttachmentList(ctx context.Context, thought *Thought) (*[]*Attachment, error) {
+	attachments := []*Attachment{}
+
+	query := store.db.Select(ctx).From("attachments")
+	query.Where("thought_id = ?", thought.ID)
+	query.OrderBy("name", "ASC")
+
+	if _, err := query.Load(&attachments); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("id", thought.ID).Msg("Error fetching attachments")
+		return &attachments, err
+	}
+
+	return &attachments, nil
+}
+
+// AttachmentGet gets a single attachment of a thought by name
+func (store *Store) AttachmentGet(ctx context.Context, attachment *Attachment) error {
+	if attachment.ThoughtID == "" || attachment.Name == "" {
+		return ErrNoAttachmentKey
+	}
+
+	query := store.db.Select(ctx).From("attachments")
+	query.Where("thought_id = ? AND name = ?", attachment.ThoughtID, attachment.Name)
+	query.Limit(1)
+
+	return query.LoadValue(&attachment)
+}
+
+// AttachmentData returns the contents of the attachment
+func (store *Store) AttachmentData(ctx context.Context, attachment *Attachment) ([]byte, error) {
+	blob := struct{ Data []byte }{}
+
+	query := store.db.Select(ctx).From("blobs").Columns("data")
+	query.Where("hash = ?", attachment.Hash)
+
+	if err := query.LoadValue(&blob); err != nil {
+		return nil, err
+	}
+
+	return blob.Data, nil
+}
+
+// AttachmentValidate checks the names and sizes of attachments that are
+// persisted together, so a request can be refused before any of them is
+// stored. The Size of every attachment must be set.
+func (store *Store) AttachmentValidate(ctx context.Context, attachments []*Attachment) error {
+	total := int64(0)
+
+	for _, attachment := range attachments {
+		if attachment.ThoughtID == "" || AttachmentName(attachment.Name) == "" {
+			return ErrNoAttachmentKey
+		}
+
+		if store.AttachmentMaxSize > 0 && attachment.Size > store.AttachmentMaxSize {
+			return ErrAttachmentTooLarge
+		}
+
+		total += attachment.Size
+	}
+
+	// Files that are already stored do not count against the quota, so this
+	// errs on the side of refusing
+	if store.AttachmentQuota > 0 && store.AttachmentUsage(ctx)+total > store.AttachmentQuota {
+		return ErrAttachmentQuota
+	}
+
+	return nil
+}
+
+// AttachmentPersist reads the contents of the attachment from reader and
+// attaches it to the thought, replacing an existing attachment with the
+// same name. The size of the file is limited by Store.AttachmentMaxSize and
+// the total size of all stored files by Store.AttachmentQuota.
+func (store *Store) AttachmentPersist(ctx context.Context, attachment *Attachment, reader io.Reader) error {
+	attachment.Name = AttachmentName(attachment.Name)
+	if attachment.ThoughtID == "" || attachment.Name == "" {
+		return ErrNoAttachmentKey
+	}
+
+	if store.AttachmentMaxSize > 0 {
+		reader = &maxBytesReader{ioutil.NopCloser(reader), store.AttachmentMaxSize}
+	}
+
+	data, err := ioutil.ReadAll(reader)
+	if err == ErrBodyTooLarge {
+		return ErrAttachmentTooLarge
+	} else if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	attachment.Hash = hex.EncodeToString(sum[:])
+	attachment.Size = int64(len(data))
+	attachment.Created = time.Now()
+
+	if mediaType, _, err := mime.ParseMediaType(attachment.ContentType); err != nil || mediaType == "application/octet-stream" {
+		attachment.ContentType = http.DetectContentType(data)
+	}
+
+	exists := 0
+	store.db.Select(ctx).From("blobs").Columns("COUNT(hash)").Where("hash = ?", attachment.Hash).LoadValue(&exists)
+
+	if exists == 0 {
+		if store.AttachmentQuota > 0 && store.AttachmentUsage(ctx)+attachment.Size > store.AttachmentQuota {
+			return ErrAttachmentQuota
+		}
+
+		query := store.db.Insert(ctx).InTo("blobs").OrIgnore()
+		query.Columns("hash", "size", "data")
+		query.Values(attachment.Hash, attachment.Size, data)
+
+		if _, err := query.Exec(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("hash", attachment.Hash).Msg("Error persisting blob")
+			return err
+		}
+	}
+
+	// Updating instead of deleting an existing attachment keeps the blob if the contents did not change
+	update := store.db.Update(ctx).Table("attachments")
+	update.Set("hash", attachment.Hash)
+	update.Set("content_type", attachment.ContentType)
+	update.Set("size", attachment.Size)
+	update.Set("created", attachment.Created)
+	update.Where("thought_id = ? AND name = ?", attachment.ThoughtID, attachment.Name)
+
+	result, err := update.Exec()
+	if err == nil {
+		if updated, _ := result.RowsAffected(); updated == 0 {
+			query := store.db.Insert(ctx).InTo("attachments")
+			query.Columns("thought_id", "name", "hash", "content_type", "size", "created")
+			query.Record(attachment)
+
+			_, err = query.Exec()
+		}
+	}
+
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("id", attachment.ThoughtID).Str("name", attachment.Name).Msg("Error persisting attachment")
+		return err
+	}
+
+	log.Ctx(ctx).Info().Str("id", attachment.ThoughtID).Str("name", attachment.Name).Int64("size", attachment.Size).Msg("Persisted attachment")
+
+	return nil
+}
+
+// AttachmentDelete removes the attachment from the thought, the contents are
+// removed when no other attachment refers to them
+func (store *Store) AttachmentDelete(ctx context.Context, attachment *Attachment) error {
+	if attachment.ThoughtID == "" || attachment.Name == "" {
+		return ErrNoAttachmentKey
+	}
+
+	query := store.db.Delete(ctx).From("attachments")
+	query.Where("thought_id = ? AND name = ?", attachment.ThoughtID, attachment.Name)
+
+	if _, err := query.Exec(); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("id", attachment.ThoughtID).Str("name", attachment.Name).Msg("Error deleting attachment")
+		return err
+	}
+
+	log.Ctx(ctx).Info().Str("id", attachment.ThoughtID).Str("name", attachment.Name).Msg("Deleted attachment")
+
+	return nil
+}
+
+// AttachmentUsage returns the total size in bytes of the stored attachments
+func (store *Store) AttachmentUsage(ctx context.Context) int64 {
+	var used int64
+
+	store.db.Select(ctx).From("blobs").Columns("COALESCE(SUM(size), 0)").LoadValue(&used)
+
+	return used
+}
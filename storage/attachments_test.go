@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAttachments(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.AttachmentMaxSize = 16
+	store.AttachmentQuota = 24
+
+	first := Thought{Content: "![diagram](attachment:my%20diagram.png)"}
+	second := Thought{Content: "[notes](attachment:notes.txt)"}
+	for _, thought := range []*Thought{&first, &second} {
+		if err := store.ThoughtPersist(ctx, thought); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attachment := Attachment{ThoughtID: first.ID, Name: "../../notes.txt"}
+	if err := store.AttachmentPersist(ctx, &attachment, strings.NewReader("hello world")); err != nil {
+		t.Fatal(err)
+	}
+
+	if attachment.Name != "notes.txt" || attachment.Size != 11 || !strings.HasPrefix(attachment.ContentType, "text/plain") {
+		t.Fatalf("Unexpected attachment %+v", attachment)
+	}
+
+	shared := Attachment{ThoughtID: second.ID, Name: "notes.txt"}
+	if err := store.AttachmentPersist(ctx, &shared, strings.NewReader("hello world")); err != nil {
+		t.Fatal(err)
+	}
+
+	if shared.Hash != attachment.Hash || store.AttachmentUsage(ctx) != 11 {
+		t.Fatalf("Expected identical files to be stored once but %d bytes are used", store.AttachmentUsage(ctx))
+	}
+
+	if err := store.AttachmentPersist(ctx, &Attachment{ThoughtID: first.ID, Name: "big"}, strings.NewReader(strings.Repeat("x", 17))); err != ErrAttachmentTooLarge {
+		t.Fatalf("Expected ErrAttachmentTooLarge but got %v", err)
+	}
+
+	if err := store.AttachmentPersist(ctx, &Attachment{ThoughtID: first.ID, Name: "quota"}, strings.NewReader(strings.Repeat("y", 16))); err != ErrAttachmentQuota {
+		t.Fatalf("Expected ErrAttachmentQuota but got %v", err)
+	}
+
+	found := Attachment{ThoughtID: first.ID, Name: "notes.txt"}
+	if err := store.AttachmentGet(ctx, &found); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := store.AttachmentData(ctx, &found); err != nil || string(data) != "hello world" {
+		t.Fatalf("Expected the contents of the attachment but got %q: %v", data, err)
+	}
+
+	// Replacing an attachment with the same contents keeps the shared blob
+	if err := store.AttachmentPersist(ctx, &Attachment{ThoughtID: first.ID, Name: "notes.txt"}, strings.NewReader("hello world")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.AttachmentDelete(ctx, &found); err != nil {
+		t.Fatal(err)
+	}
+
+	if attachments, _ := store.AttachmentList(ctx, &first); len(*attachments) != 0 {
+		t.Fatalf("Expected no attachments but got %d", len(*attachments))
+	}
+
+	if data, err := store.AttachmentData(ctx, &shared); err != nil || string(data) != "hello world" {
+		t.Fatalf("Expected the shared contents to be kept but got %q: %v", data, err)
+	}
+
+	if err := store.ThoughtDelete(ctx, &second); err != nil {
+		t.Fatal(err)
+	}
+
+	if used := store.AttachmentUsage(ctx); used != 0 {
+		t.Fatalf("Expected unreferenced contents to be removed but %d bytes are used", used)
+	}
+}
+
+func TestAttachmentReferences(t *testing.T) {
+	thought := Thought{ID: "abc", Content: "![diagram](<attachment:my diagram.png>) [notes](attachment:notes.txt) [site](https://example.com)"}
+
+	rendered, err := thought.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		`<img src="/api/thoughts/abc/attachments/my%20diagram.png" alt="diagram">`,
+		`<a href="/api/thoughts/abc/attachments/notes.txt">notes</a>`,
+		`<a href="https://example.com">site</a>`,
+	} {
+		if !strings.Contains(rendered, expected) {
+			t.Fatalf("Expected %s in %s", expected, rendered)
+		}
+	}
+
+	if rendered, _ := RenderMarkdown("[notes](attachment:notes.txt)"); strings.Contains(rendered, "/api/thoughts") {
+		t.Fatalf("Expected attachments not to be resolved without a thought but got %s", rendered)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/go-shiori/go-readability"
 	"github.com/rs/zerolog/log"
 )
 
@@ -20,14 +19,24 @@ var (
 
 // Bookmark represents a single bookmark
 type Bookmark struct {
-	ID      string
-	URL     string
-	Title   string
-	Created time.Time
-	Updated time.Time
-	Excerpt string
-	Content string `json:",omitempty"`
-	Tags    Tags
+	ID          string
+	URL         string
+	Title       string
+	Created     time.Time
+	Updated     time.Time
+	Excerpt     string
+	Content     string `json:",omitempty"`
+	Author      string
+	SiteName    string
+	Published   time.Time
+	Image       string
+	Favicon     string
+	Language    string
+	ReadingTime int
+	ContentType string
+	Pages       int
+	Tags        Tags
+	Version     int
 }
 
 // Fetch downloads the bookmark, reduces the result to a readable plain text format
@@ -40,7 +49,11 @@ func (bookmark *Bookmark) Fetch(ctx context.Context) error {
 
 	logger.Info().Msg("Fetching bookmark")
 
-	article, err := readability.FromURL(bookmark.URL, 5*time.Second)
+	page, err := fetchPage(ctx, bookmark.URL)
+	if err == nil {
+		err = page.extract(ctx, bookmark)
+	}
+
 	if err != nil {
 		bookmark.Title = bookmark.URL
 		bookmark.Content = "Error fetching bookmark"
@@ -49,19 +62,6 @@ func (bookmark *Bookmark) Fetch(ctx context.Context) error {
 		return err
 	}
 
-	bookmark.Title = article.Title
-	bookmark.Content = article.TextContent
-
-	if article.Excerpt == "" {
-		size := 260
-		if len(article.TextContent) < size {
-			size = len(article.TextContent)
-		}
-		bookmark.Excerpt = article.TextContent[0:size]
-	} else {
-		bookmark.Excerpt = article.Excerpt
-	}
-
 	logger.Info().Msg("Successfully fetched bookmark")
 
 	return nil
@@ -69,10 +69,25 @@ func (bookmark *Bookmark) Fetch(ctx context.Context) error {
 
 // BookmarkListOptions can be passed to BookmarkList to filter bookmarks
 type BookmarkListOptions struct {
-	Search string
-	Tags   Tags
-	Limit  int
-	Offset int
+	Search   string
+	Tags     Tags
+	Author   string
+	SiteName string
+	Language string
+	Sort     string
+	Limit    int
+	Offset   int
+}
+
+// bookmarkSortColumns are the columns that can be passed to BookmarkListOptions.Sort, prefix with - to sort descending
+var bookmarkSortColumns = map[string]bool{
+	"created":      true,
+	"updated":      true,
+	"published":    true,
+	"title":        true,
+	"author":       true,
+	"site_name":    true,
+	"reading_time": true,
 }
 
 // BookmarkList fetches multiple bookmarks from the database
@@ -93,6 +108,18 @@ func (store *Store) BookmarkList(ctx context.Context, options *BookmarkListOptio
 		}
 	}
 
+	if options.Author != "" {
+		query.Where("author = ? COLLATE NOCASE", options.Author)
+	}
+
+	if options.SiteName != "" {
+		query.Where("site_name = ? COLLATE NOCASE", options.SiteName)
+	}
+
+	if options.Language != "" {
+		query.Where("language = ?", strings.ToLower(options.Language))
+	}
+
 	bookmarks := []*Bookmark{}
 	totalCount := 0
 
@@ -102,8 +129,17 @@ func (store *Store) BookmarkList(ctx context.Context, options *BookmarkListOptio
 		return &bookmarks, 0
 	}
 
-	query.Columns("id", "created", "updated", "title", "url", "excerpt", "tags")
-	query.OrderBy("created", "DESC")
+	if column := strings.TrimPrefix(options.Sort, "-"); bookmarkSortColumns[column] {
+		if strings.HasPrefix(options.Sort, "-") {
+			query.OrderBy(column, "DESC")
+		} else {
+			query.OrderBy(column, "ASC")
+		}
+	} else {
+		query.OrderBy("created", "DESC")
+	}
+
+	query.Columns("id", "created", "updated", "title", "url", "excerpt", "author", "site_name", "published", "image", "favicon", "language", "reading_time", "content_type", "pages", "tags", "version")
 	query.Limit(options.Limit)
 	query.Offset(options.Offset)
 	if _, err := query.Load(&bookmarks); err != nil {
@@ -154,14 +190,20 @@ func (store *Store) BookmarkPersist(ctx context.Context, bookmark *Bookmark) err
 
 	bookmark.Updated = time.Now()
 
-	// Check if there is already a bookmark with the same URL in the database
+	// Check if there is already a bookmark with the same URL in the database,
+	// a bookmark that is saved again by URL replaces whatever version is stored
+	replace := bookmark.ID == ""
 	store.db.Select(ctx).From("bookmarks").Columns("id", "created").Where("url = ?", bookmark.URL).Limit(1).LoadValue(&bookmark)
 
+	event := EventBookmarkUpdated
+
 	if bookmark.ID == "" {
+		event = EventBookmarkCreated
 		bookmark.ID = generateUUID()
+		bookmark.Version = 1
 
 		query := store.db.Insert(ctx).InTo("bookmarks")
-		query.Columns("id", "created", "content", "excerpt", "tags", "title", "updated", "url")
+		query.Columns("id", "created", "author", "content", "content_type", "excerpt", "favicon", "image", "language", "pages", "published", "reading_time", "site_name", "tags", "title", "updated", "url", "version")
 		query.Record(bookmark)
 
 		if _, err := query.Exec(); err != nil {
@@ -170,20 +212,30 @@ func (store *Store) BookmarkPersist(ctx context.Context, bookmark *Bookmark) err
 		}
 	} else {
 		query := store.db.Update(ctx).Table("bookmarks")
+		query.Set("author", bookmark.Author)
 		query.Set("content", bookmark.Content)
+		query.Set("content_type", bookmark.ContentType)
 		query.Set("excerpt", bookmark.Excerpt)
+		query.Set("favicon", bookmark.Favicon)
+		query.Set("image", bookmark.Image)
+		query.Set("language", bookmark.Language)
+		query.Set("pages", bookmark.Pages)
+		query.Set("published", bookmark.Published)
+		query.Set("reading_time", bookmark.ReadingTime)
+		query.Set("site_name", bookmark.SiteName)
 		query.Set("tags", bookmark.Tags)
 		query.Set("title", bookmark.Title)
 		query.Set("updated", bookmark.Updated)
 		query.Set("url", bookmark.URL)
-		query.Where("id = ?", bookmark.ID)
 
-		if _, err := query.Exec(); err != nil {
-			log.Ctx(ctx).Error().Err(err).Str("id", bookmark.ID).Str("url", bookmark.URL).Msg("Error updating bookmark")
+		if err := store.updateVersioned(ctx, query, "bookmarks", bookmark.ID, &bookmark.Version, replace); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("id", bookmark.ID).Str("url", bookmark.URL).Int("version", bookmark.Version).Msg("Error updating bookmark")
 			return err
 		}
 	}
 
+	store.emit(ctx, event, bookmark.ID, bookmark.Tags)
+
 	log.Ctx(ctx).Info().Str("id", bookmark.ID).Str("url", bookmark.URL).Msg("Persisted bookmark")
 
 	return nil
@@ -210,6 +262,8 @@ func (store *Store) BookmarkDelete(ctx context.Context, bookmark *Bookmark) erro
 		return err
 	}
 
+	store.emit(ctx, EventBookmarkDeleted, bookmark.ID, bookmark.Tags)
+
 	log.Ctx(ctx).Info().Str("id", bookmark.ID).Str("url", bookmark.URL).Msg("Bookmark deleted")
 
 	return nil
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// wordsPerMinute is the reading speed used to estimate the reading time of a bookmark
+	wordsPerMinute = 200
+)
+
+// readingTime estimates the number of minutes it takes to read the text
+func readingTime(text string) int {
+	words := len(strings.Fields(text))
+
+	return int(math.Ceil(float64(words) / wordsPerMinute))
+}
+
+// parsePublished parses the commonly used date formats of published dates in html meta tags
+func parsePublished(value string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05Z0700", "2006-01-02T15:04:05", "2006-01-02"} {
+		if published, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return published
+		}
+	}
+
+	return time.Time{}
+}
+
+// metadata fills the empty metadata fields of the bookmark using meta tags and JSON-LD of the page
+func (page *Page) metadata(bookmark *Bookmark) {
+	document, err := page.Document()
+	if err != nil {
+		return
+	}
+
+	linkedData := page.linkedData()
+
+	if bookmark.Author == "" {
+		bookmark.Author = firstNonEmpty(page.Meta("author", "article:author", "twitter:creator"), linkedData["author"])
+	}
+
+	if bookmark.SiteName == "" {
+		bookmark.SiteName = firstNonEmpty(page.Meta("og:site_name", "application-name"), linkedData["publisher"])
+	}
+
+	if bookmark.Published.IsZero() {
+		bookmark.Published = parsePublished(firstNonEmpty(page.Meta("article:published_time", "date", "dc.date"), linkedData["datePublished"]))
+	}
+
+	if bookmark.Image == "" {
+		bookmark.Image = firstNonEmpty(page.Meta("og:image", "twitter:image"), linkedData["image"])
+	}
+
+	if bookmark.Favicon == "" {
+		href, _ := document.Find("link[rel='icon'], link[rel='shortcut icon'], link[rel='apple-touch-icon']").First().Attr("href")
+		bookmark.Favicon = firstNonEmpty(href, "/favicon.ico")
+	}
+
+	bookmark.Image = page.absoluteURL(bookmark.Image)
+	bookmark.Favicon = page.absoluteURL(bookmark.Favicon)
+
+	if bookmark.Language == "" {
+		lang, _ := document.Find("html").First().Attr("lang")
+		bookmark.Language = firstNonEmpty(lang, page.Meta("og:locale", "language"))
+		bookmark.Language = strings.ToLower(strings.SplitN(strings.ReplaceAll(bookmark.Language, "_", "-"), "-", 2)[0])
+	}
+
+	bookmark.ReadingTime = readingTime(bookmark.Content)
+}
+
+func (page *Page) absoluteURL(href string) string {
+	if href == "" {
+		return ""
+	}
+
+	absolute, err := page.URL.Parse(href)
+	if err != nil {
+		return ""
+	}
+
+	return absolute.String()
+}
+
+// linkedData returns the author, publisher, datePublished and image properties found in the JSON-LD of the page
+func (page *Page) linkedData() map[string]string {
+	properties := map[string]string{}
+
+	document, err := page.Document()
+	if err != nil {
+		return properties
+	}
+
+	document.Find("script[type='application/ld+json']").Each(func(i int, selection *goquery.Selection) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(selection.Text()), &data); err != nil {
+			return
+		}
+
+		collectLinkedData(data, properties)
+	})
+
+	return properties
+}
+
+func collectLinkedData(data interface{}, properties map[string]string) {
+	switch value := data.(type) {
+	case []interface{}:
+		for _, item := range value {
+			collectLinkedData(item, properties)
+		}
+	case map[string]interface{}:
+		for _, key := range []string{"author", "publisher", "datePublished", "image"} {
+			if _, ok := properties[key]; !ok {
+				if name := linkedDataName(value[key]); name != "" {
+					properties[key] = name
+				}
+			}
+		}
+
+		if graph, ok := value["@graph"]; ok {
+			collectLinkedData(graph, properties)
+		}
+	}
+}
+
+// linkedDataName returns a string, or the name or url of an object or the first item of a list
+func linkedDataName(data interface{}) string {
+	switch value := data.(type) {
+	case string:
+		return value
+	case []interface{}:
+		if len(value) > 0 {
+			return linkedDataName(value[0])
+		}
+	case map[string]interface{}:
+		if name, ok := value["name"].(string); ok {
+			return name
+		}
+		if url, ok := value["url"].(string); ok {
+			return url
+		}
+	}
+
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
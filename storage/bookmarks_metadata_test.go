@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPageMetadata(t *testing.T) {
+	pageURL, _ := url.Parse("https://blog.example.com/posts/1")
+	page := &Page{URL: pageURL, Body: []byte(`<html lang="en_US"><head>
+		<meta property="og:site_name" content="Example blog">
+		<meta property="og:image" content="/images/lead.png">
+		<link rel="icon" href="/static/icon.png">
+		<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+			{"@type": "BlogPosting", "datePublished": "2020-03-04T05:06:07Z", "author": [{"@type": "Person", "name": "Jane Doe"}]}
+		]}</script>
+	</head><body></body></html>`)}
+
+	bookmark := &Bookmark{Content: strings.Repeat("word ", 450)}
+	page.metadata(bookmark)
+
+	if bookmark.Author != "Jane Doe" || bookmark.SiteName != "Example blog" || bookmark.Language != "en" {
+		t.Fatalf("Unexpected bookmark %+v", bookmark)
+	}
+
+	if !bookmark.Published.Equal(time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)) {
+		t.Fatalf("Unexpected published date %s", bookmark.Published)
+	}
+
+	if bookmark.Image != "https://blog.example.com/images/lead.png" || bookmark.Favicon != "https://blog.example.com/static/icon.png" {
+		t.Fatalf("Unexpected images %s and %s", bookmark.Image, bookmark.Favicon)
+	}
+
+	if bookmark.ReadingTime != 3 {
+		t.Fatalf("Expected a reading time of 3 minutes but got %d", bookmark.ReadingTime)
+	}
+
+	bookmark = &Bookmark{Author: "Someone else"}
+	page.metadata(bookmark)
+
+	if bookmark.Author != "Someone else" {
+		t.Fatalf("Expected existing metadata to be kept but got %s", bookmark.Author)
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrNoSecret is returned if a value must be encrypted but Store.Secret is empty
+	ErrNoSecret = errors.New("No secret configured to encrypt values")
+)
+
+// newGCM returns the cipher that encrypts values with a key derived from Store.Secret
+func (store *Store) newGCM() (cipher.AEAD, error) {
+	if store.Secret == "" {
+		return nil, ErrNoSecret
+	}
+
+	key := sha256.Sum256([]byte(store.Secret))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encrypt encrypts the plaintext using AES-GCM and returns it base64 encoded, prefixed with the nonce
+func (store *Store) encrypt(plaintext []byte) (string, error) {
+	gcm, err := store.newGCM()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// decrypt reverses encrypt
+func (store *Store) decrypt(value string) ([]byte, error) {
+	gcm, err := store.newGCM()
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("Encrypted value is too short")
+	}
+
+	nonce := ciphertext[:gcm.NonceSize()]
+
+	return gcm.Open(nil, nonce, ciphertext[gcm.NonceSize():], nil)
+}
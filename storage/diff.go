@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around changes
+	diffContext = 3
+
+	// maxDiffCells limits the size of the table used to compute the longest
+	// common subsequence to about 1MB, larger changes are shown as a replace of all lines
+	maxDiffCells = 256 * 1024
+)
+
+type diffLine struct {
+	op   byte
+	text string
+	old  int
+	new  int
+}
+
+// UnifiedDiff returns the differences between two texts in the unified diff format
+func UnifiedDiff(from, to, fromName, toName string) string {
+	ops := diffLines(splitLines(from), splitLines(to))
+
+	var builder strings.Builder
+
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].op == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk until there are more than two times the context of unchanged lines
+		end, unchanged := start, 0
+		for i := start; i < len(ops) && unchanged <= 2*diffContext; i++ {
+			if ops[i].op == ' ' {
+				unchanged++
+			} else {
+				unchanged, end = 0, i+1
+			}
+		}
+
+		first := start - diffContext
+		if first < 0 {
+			first = 0
+		}
+
+		last := end + diffContext
+		if last > len(ops) {
+			last = len(ops)
+		}
+
+		if builder.Len() == 0 {
+			fmt.Fprintf(&builder, "--- %s\n+++ %s\n", fromName, toName)
+		}
+
+		writeHunk(&builder, ops[first:last])
+		start = last
+	}
+
+	return builder.String()
+}
+
+func writeHunk(builder *strings.Builder, hunk []diffLine) {
+	oldCount, newCount := 0, 0
+	for _, line := range hunk {
+		if line.op != '+' {
+			oldCount++
+		}
+		if line.op != '-' {
+			newCount++
+		}
+	}
+
+	oldStart, newStart := hunk[0].old, hunk[0].new
+	if oldCount > 0 {
+		oldStart++
+	}
+	if newCount > 0 {
+		newStart++
+	}
+
+	fmt.Fprintf(builder, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+
+	for _, line := range hunk {
+		builder.WriteByte(line.op)
+		builder.WriteString(line.text)
+		builder.WriteByte('\n')
+	}
+}
+
+// diffLines computes the edit script between two lists of lines using their longest common subsequence
+func diffLines(a, b []string) []diffLine {
+	// Unchanged lines at the start and the end do not need to be part of the table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(middleA), len(middleB)
+
+	ops := []diffLine{}
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffLine{' ', a[i], i, i})
+	}
+
+	if (n+1)*(m+1) > maxDiffCells {
+		// Too large to compare, replace all lines
+		for i, line := range middleA {
+			ops = append(ops, diffLine{'-', line, prefix + i, prefix})
+		}
+		for j, line := range middleB {
+			ops = append(ops, diffLine{'+', line, prefix + n, prefix + j})
+		}
+	} else {
+		lengths := make([][]int32, n+1)
+		for i := range lengths {
+			lengths[i] = make([]int32, m+1)
+		}
+
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if middleA[i] == middleB[j] {
+					lengths[i][j] = lengths[i+1][j+1] + 1
+				} else if lengths[i+1][j] >= lengths[i][j+1] {
+					lengths[i][j] = lengths[i+1][j]
+				} else {
+					lengths[i][j] = lengths[i][j+1]
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && middleA[i] == middleB[j]:
+				ops = append(ops, diffLine{' ', middleA[i], prefix + i, prefix + j})
+				i++
+				j++
+			case j < m && (i == n || lengths[i][j+1] > lengths[i+1][j]):
+				ops = append(ops, diffLine{'+', middleB[j], prefix + i, prefix + j})
+				j++
+			default:
+				ops = append(ops, diffLine{'-', middleA[i], prefix + i, prefix + j})
+				i++
+			}
+		}
+	}
+
+	for k := 0; k < suffix; k++ {
+		ops = append(ops, diffLine{' ', a[len(a)-suffix+k], len(a) - suffix + k, len(b) - suffix + k})
+	}
+
+	return ops
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\ntwelve\n"
+	to := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\ntwelve\nthirteen\n"
+
+	expected := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -10,3 +10,4 @@
+ ten
+ eleven
+ twelve
++thirteen
+`
+
+	if diff := UnifiedDiff(from, to, "a", "b"); diff != expected {
+		t.Fatalf("Unexpected diff:\n%s", diff)
+	}
+
+	expected = `--- a
++++ b
+@@ -0,0 +1,2 @@
++hello
++world
+`
+
+	if diff := UnifiedDiff("", "hello\nworld", "a", "b"); diff != expected {
+		t.Fatalf("Unexpected diff:\n%s", diff)
+	}
+
+	if diff := UnifiedDiff(from, from, "a", "b"); diff != "" {
+		t.Fatalf("Expected no differences but got:\n%s", diff)
+	}
+
+	// Changes too large to compare are shown as a replace of all changed lines
+	var large, changed strings.Builder
+	for i := 0; i < 600; i++ {
+		fmt.Fprintf(&large, "line %d\n", i)
+		if i%2 == 0 {
+			fmt.Fprintf(&changed, "line %d\n", i)
+		} else {
+			fmt.Fprintf(&changed, "changed %d\n", i)
+		}
+	}
+
+	diff := UnifiedDiff(large.String(), changed.String(), "a", "b")
+	if !strings.Contains(diff, "\n-line 2\n") || strings.Contains(diff, "\n line 2\n") {
+		t.Fatalf("Expected all changed lines to be replaced but got:\n%.200s", diff)
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// extractPDF fills the bookmark with the title, author, text and page count of a pdf document
+func (page *Page) extractPDF(bookmark *Bookmark) (err error) {
+	// The pdf reader panics on some malformed documents
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Invalid pdf document: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(page.Body), int64(len(page.Body)))
+	if err != nil {
+		return err
+	}
+
+	text, err := reader.GetPlainText()
+	if err != nil {
+		return err
+	}
+
+	content, err := ioutil.ReadAll(text)
+	if err != nil {
+		return err
+	}
+
+	info := reader.Trailer().Key("Info")
+
+	bookmark.Content = strings.TrimSpace(strings.ToValidUTF8(string(content), ""))
+	bookmark.Title = firstNonEmpty(info.Key("Title").Text(), firstLine(bookmark.Content), page.fileName())
+	bookmark.Author = info.Key("Author").Text()
+	bookmark.Published = parsePDFDate(info.Key("CreationDate").Text())
+	bookmark.Pages = reader.NumPage()
+	bookmark.Excerpt = excerpt(bookmark.Content)
+
+	return nil
+}
+
+// extractText fills the bookmark with the contents of a plain text document
+func (page *Page) extractText(bookmark *Bookmark) error {
+	bookmark.Content = strings.TrimSpace(strings.ToValidUTF8(string(page.Body), ""))
+	bookmark.Title = firstNonEmpty(firstLine(bookmark.Content), page.fileName())
+	bookmark.Excerpt = excerpt(bookmark.Content)
+
+	return nil
+}
+
+// extractImage fills the bookmark with the file name and dimensions of an image
+func (page *Page) extractImage(bookmark *Bookmark) error {
+	bookmark.Title = page.fileName()
+	bookmark.Image = page.URL.String()
+	bookmark.Content = ""
+	bookmark.Excerpt = "Image"
+
+	if config, format, err := image.DecodeConfig(bytes.NewReader(page.Body)); err == nil {
+		bookmark.Excerpt = fmt.Sprintf("Image (%s, %dx%d pixels)", format, config.Width, config.Height)
+	}
+
+	return nil
+}
+
+// extractFile fills the bookmark of a document that cannot be reduced to text
+func (page *Page) extractFile(bookmark *Bookmark) error {
+	bookmark.Title = page.fileName()
+	bookmark.Content = ""
+	bookmark.Excerpt = page.ContentType
+
+	return nil
+}
+
+// fileName returns the last element of the path of the page, or the host name
+func (page *Page) fileName() string {
+	if name := path.Base(page.URL.Path); name != "/" && name != "." {
+		return name
+	}
+
+	return page.URL.Host
+}
+
+// firstLine returns the first non empty line of the text, shortened to 120 characters
+func firstLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		} else if utf8.RuneCountInString(line) > 120 {
+			return string([]rune(line)[:120])
+		} else {
+			return line
+		}
+	}
+
+	return ""
+}
+
+// parsePDFDate parses dates in the D:YYYYMMDDHHmmSS format used by pdf documents
+func parsePDFDate(value string) time.Time {
+	value = strings.TrimPrefix(value, "D:")
+	value = strings.Replace(strings.TrimSuffix(value, "'"), "'", "", -1)
+
+	for _, layout := range []string{"20060102150405Z0700", "20060102150405Z", "20060102150405", "20060102"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// minimalPDF builds a single page pdf document containing the given text
+func minimalPDF(title, text string) []byte {
+	stream := fmt.Sprintf("BT /F1 12 Tf 72 712 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Title (%s) /Author (Jane Doe) /CreationDate (D:20200304050607Z) >>", title),
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("%PDF-1.4\n")
+
+	offsets := []int{}
+	for i, object := range objects {
+		offsets = append(offsets, buffer.Len())
+		fmt.Fprintf(&buffer, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buffer.Len()
+	fmt.Fprintf(&buffer, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buffer, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buffer, "trailer\n<< /Size %d /Root 1 0 R /Info 6 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buffer.Bytes()
+}
+
+func TestExtractDocuments(t *testing.T) {
+	var picture bytes.Buffer
+	png.Encode(&picture, image.NewRGBA(image.Rect(0, 0, 30, 20)))
+
+	tests := []struct {
+		path        string
+		contentType string
+		body        []byte
+		expected    Bookmark
+	}{
+		{"/paper.pdf", "application/pdf", minimalPDF("A paper", "Hello world"), Bookmark{Title: "A paper", Author: "Jane Doe", Content: "Hello world", Pages: 1, ContentType: "application/pdf"}},
+		{"/download", "", minimalPDF("", "Detected"), Bookmark{Title: "Detected", Author: "Jane Doe", Content: "Detected", Pages: 1, ContentType: "application/pdf"}},
+		{"/notes.txt", "text/plain; charset=utf-8", []byte("\n  First line\nSecond line"), Bookmark{Title: "First line", Content: "First line\nSecond line", ContentType: "text/plain"}},
+		{"/image.png", "image/png", picture.Bytes(), Bookmark{Title: "image.png", Excerpt: "Image (png, 30x20 pixels)", ContentType: "image/png"}},
+		{"/archive.zip", "application/zip", []byte("PK"), Bookmark{Title: "archive.zip", Excerpt: "application/zip", ContentType: "application/zip"}},
+	}
+
+	for _, test := range tests {
+		pageURL, _ := url.Parse("https://example.com" + test.path)
+		page := &Page{URL: pageURL, ContentType: test.contentType, Body: test.body}
+
+		bookmark := &Bookmark{}
+		if err := page.extract(context.Background(), bookmark); err != nil {
+			t.Fatalf("Error extracting %s: %v", test.path, err)
+		}
+
+		if bookmark.Title != test.expected.Title || bookmark.Author != test.expected.Author || bookmark.Pages != test.expected.Pages || bookmark.ContentType != test.expected.ContentType {
+			t.Fatalf("Unexpected bookmark for %s: %+v", test.path, bookmark)
+		}
+
+		if bookmark.Content != test.expected.Content || (test.expected.Excerpt != "" && bookmark.Excerpt != test.expected.Excerpt) {
+			t.Fatalf("Unexpected content for %s: %q %q", test.path, bookmark.Content, bookmark.Excerpt)
+		}
+	}
+}
+
+func TestParsePDFDate(t *testing.T) {
+	expected := time.Date(2020, 3, 4, 5, 6, 7, 0, time.FixedZone("", 2*60*60))
+
+	if date := parsePDFDate("D:20200304050607+02'00'"); !date.Equal(expected) {
+		t.Fatalf("Expected %s but got %s", expected, date)
+	}
+
+	if date := parsePDFDate("yesterday"); !date.IsZero() {
+		t.Fatalf("Expected a zero date but got %s", date)
+	}
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// EventBookmarkCreated is emitted when a new bookmark is persisted
+	EventBookmarkCreated = "bookmark.created"
+
+	// EventBookmarkUpdated is emitted when an existing bookmark is persisted
+	EventBookmarkUpdated = "bookmark.updated"
+
+	// EventBookmarkDeleted is emitted when a bookmark is deleted
+	EventBookmarkDeleted = "bookmark.deleted"
+
+	// EventFeedCreated is emitted when a new feed is persisted
+	EventFeedCreated = "feed.created"
+
+	// EventFeedUpdated is emitted when an existing feed is persisted
+	EventFeedUpdated = "feed.updated"
+
+	// EventFeedRefreshed is emitted when a refreshed or pushed feed has new items
+	EventFeedRefreshed = "feed.refreshed"
+
+	// EventFeedDeleted is emitted when a feed is deleted
+	EventFeedDeleted = "feed.deleted"
+
+	// EventThoughtCreated is emitted when a new thought is persisted
+	EventThoughtCreated = "thought.created"
+
+	// EventThoughtUpdated is emitted when an existing thought is persisted
+	EventThoughtUpdated = "thought.updated"
+
+	// EventThoughtDeleted is emitted when a thought is deleted
+	EventThoughtDeleted = "thought.deleted"
+
+	// eventHistory is the number of events kept to resume from
+	eventHistory = 10000
+
+	// eventBuffer is the number of events a subscriber can fall behind before it is dropped
+	eventBuffer = 64
+)
+
+// Event is a change of a bookmark, feed or thought
+type Event struct {
+	ID       int64
+	Created  time.Time
+	Type     string
+	TargetID string
+	Tags     Tags
+}
+
+// Matches returns true if the type of the event equals or starts with one of
+// the types, or if there are no types
+func (event *Event) Matches(types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+
+	for _, kind := range types {
+		if event.Type == kind || strings.HasPrefix(event.Type, kind+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// eventBus delivers new events to the subscribers
+type eventBus struct {
+	sync.Mutex
+	subscribers map[chan *Event]bool
+
+	// sequence is held while an event is stored and delivered, so events are
+	// delivered in the order of their ID without blocking the subscribers
+	sequence sync.Mutex
+}
+
+// Subscribe returns a channel receiving all new events and a function to stop
+// receiving them. The channel is closed if the subscriber falls behind, it
+// can catch up using EventList.
+func (store *Store) Subscribe() (<-chan *Event, func()) {
+	events := make(chan *Event, eventBuffer)
+
+	store.events.Lock()
+	if store.events.subscribers == nil {
+		store.events.subscribers = map[chan *Event]bool{}
+	}
+	store.events.subscribers[events] = true
+	store.events.Unlock()
+
+	unsubscribe := func() {
+		store.events.Lock()
+		if store.events.subscribers[events] {
+			delete(store.events.subscribers, events)
+			close(events)
+		}
+		store.events.Unlock()
+	}
+
+	return events, unsubscribe
+}
+
+// EventList returns the events after the event with the given ID, oldest first
+func (store *Store) EventList(ctx context.Context, after int64, limit int) ([]*Event, error) {
+	events := []*Event{}
+
+	query := store.db.Select(ctx).From("events")
+	query.Where("id > ?", after)
+	query.OrderBy("id", "ASC")
+	query.Limit(limit)
+
+	if _, err := query.Load(&events); err != nil {
+		return events, err
+	}
+
+	return events, nil
+}
+
+// EventLatest returns the ID of the most recent event, or 0 if there are no events
+func (store *Store) EventLatest(ctx context.Context) int64 {
+	var latest int64
+
+	store.db.Select(ctx).From("events").Columns("COALESCE(MAX(id), 0)").LoadValue(&latest)
+
+	return latest
+}
+
+// emit stores the event and delivers it to the subscribers
+func (store *Store) emit(ctx context.Context, kind, targetID string, tags Tags) {
+	event := Event{
+		Created:  time.Now(),
+		Type:     kind,
+		TargetID: targetID,
+		Tags:     tags,
+	}
+
+	if event.Tags == nil {
+		event.Tags = Tags{}
+	}
+
+	// The event is stored even if the request that caused it is cancelled
+	ctx = log.Ctx(ctx).WithContext(context.Background())
+
+	store.events.sequence.Lock()
+
+	query := store.db.Insert(ctx).InTo("events")
+	query.Columns("created", "type", "target_id", "tags")
+	query.Record(&event)
+
+	// The query sets the ID of the event
+	if _, err := query.Exec(); err != nil {
+		store.events.sequence.Unlock()
+		log.Ctx(ctx).Warn().Err(err).Str("type", kind).Str("id", targetID).Msg("Error storing event")
+		return
+	}
+
+	store.events.Lock()
+	for subscriber := range store.events.subscribers {
+		select {
+		case subscriber <- &event:
+		default:
+			delete(store.events.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+	store.events.Unlock()
+
+	store.events.sequence.Unlock()
+
+	if event.ID%100 == 0 {
+		store.db.Delete(ctx).From("events").Where("id <= ?", event.ID-eventHistory).Exec()
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, unsubscribe := store.Subscribe()
+
+	thought := Thought{Content: "hello", Tags: Tags{"work"}}
+	store.ThoughtPersist(ctx, &thought)
+	store.ThoughtPersist(ctx, &thought)
+	store.ThoughtDelete(ctx, &thought)
+
+	bookmark := Bookmark{URL: "https://example.com/"}
+	store.BookmarkPersist(ctx, &bookmark)
+
+	expected := []string{EventThoughtCreated, EventThoughtUpdated, EventThoughtDeleted, EventBookmarkCreated}
+	for i, kind := range expected {
+		event := <-events
+		if event.Type != kind || event.ID != int64(i+1) {
+			t.Fatalf("Expected event %d to be %s but got %+v", i+1, kind, event)
+		}
+	}
+
+	if event := expectEvent(events); event != nil {
+		t.Fatalf("Did not expect another event but got %+v", event)
+	}
+
+	unsubscribe()
+
+	if _, open := <-events; open {
+		t.Fatal("Expected the channel to be closed after unsubscribing")
+	}
+
+	missed, err := store.EventList(ctx, 2, -1)
+	if err != nil || len(missed) != 2 || missed[0].Type != EventThoughtDeleted || missed[0].Tags[0] != "work" {
+		t.Fatalf("Expected to resume after event 2 but got %+v: %v", missed, err)
+	}
+
+	// A subscriber that falls behind is dropped
+	lagging, unsubscribe := store.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i <= eventBuffer; i++ {
+		store.BookmarkPersist(ctx, &bookmark)
+	}
+
+	received := 0
+	for range lagging {
+		received++
+	}
+
+	if received != eventBuffer {
+		t.Fatalf("Expected %d events before the subscriber was dropped but got %d", eventBuffer, received)
+	}
+}
+
+func TestEmitCancelled(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	store, err := New(context.Background(), filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, unsubscribe := store.Subscribe()
+	defer unsubscribe()
+
+	// The request that caused the change can be gone by the time the event is stored
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	store.emit(ctx, EventThoughtUpdated, "1", Tags{})
+
+	if event := expectEvent(events); event == nil || event.Type != EventThoughtUpdated {
+		t.Fatalf("Expected the event to be delivered but got %+v", event)
+	}
+
+	if stored, err := store.EventList(context.Background(), 0, 10); err != nil || len(stored) != 1 {
+		t.Fatalf("Expected the event to be stored but got %d: %v", len(stored), err)
+	}
+}
+
+func expectEvent(events <-chan *Event) *Event {
+	select {
+	case event := <-events:
+		return event
+	default:
+		return nil
+	}
+}
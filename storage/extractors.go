@@ -0,0 +1,327 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-shiori/go-readability"
+	"github.com/rs/zerolog/log"
+)
+
+// Extractors are consulted in order by Bookmark.Fetch before falling back to readability
+var Extractors = []Extractor{
+	&OEmbedExtractor{},
+}
+
+// RegisterExtractor adds an extractor in front of the existing Extractors
+func RegisterExtractor(extractor Extractor) {
+	Extractors = append([]Extractor{extractor}, Extractors...)
+}
+
+// Extractor extracts the title, content and excerpt of a bookmark from a downloaded page
+type Extractor interface {
+	// Extract fills the bookmark using the page and returns false if the extractor does not support the page
+	Extract(ctx context.Context, page *Page, bookmark *Bookmark) (bool, error)
+}
+
+// Page represents a downloaded web page
+type Page struct {
+	URL         *url.URL
+	ContentType string
+	Body        []byte
+
+	document *goquery.Document
+}
+
+// Document returns the parsed html of the page
+func (page *Page) Document() (*goquery.Document, error) {
+	if page.document != nil {
+		return page.document, nil
+	}
+
+	document, err := goquery.NewDocumentFromReader(bytes.NewReader(page.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	document.Url = page.URL
+	page.document = document
+
+	return document, nil
+}
+
+// Meta returns the content of the first meta tag with one of the given names or properties
+func (page *Page) Meta(names ...string) string {
+	document, err := page.Document()
+	if err != nil {
+		return ""
+	}
+
+	for _, name := range names {
+		if content, ok := document.Find("meta[name='" + name + "'], meta[property='" + name + "']").First().Attr("content"); ok && content != "" {
+			return strings.TrimSpace(content)
+		}
+	}
+
+	return ""
+}
+
+// fetchPage downloads the given url
+func fetchPage(ctx context.Context, url string) (*Page, error) {
+	response, err := DefaultFetcher.Get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Page{
+		URL:         response.Request.URL,
+		ContentType: response.Header.Get("Content-Type"),
+		Body:        body,
+	}, nil
+}
+
+// extract fills the bookmark based on the content type of the page, html pages
+// are handled by the first extractor that supports the page or the generic
+// readability extraction
+func (page *Page) extract(ctx context.Context, bookmark *Bookmark) error {
+	mediaType, _, _ := mime.ParseMediaType(page.ContentType)
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(page.Body))
+	}
+
+	bookmark.ContentType = mediaType
+
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return page.extractHTML(ctx, bookmark)
+	case mediaType == "application/pdf":
+		return page.extractDocument(bookmark, page.extractPDF)
+	case mediaType == "text/plain" || mediaType == "text/markdown":
+		return page.extractDocument(bookmark, page.extractText)
+	case strings.HasPrefix(mediaType, "image/"):
+		return page.extractDocument(bookmark, page.extractImage)
+	default:
+		return page.extractDocument(bookmark, page.extractFile)
+	}
+}
+
+// extractDocument fills the bookmark of a document that is not a html page
+func (page *Page) extractDocument(bookmark *Bookmark, extract func(*Bookmark) error) error {
+	if err := extract(bookmark); err != nil {
+		return err
+	}
+
+	bookmark.Favicon = page.absoluteURL("/favicon.ico")
+	bookmark.ReadingTime = readingTime(bookmark.Content)
+
+	return nil
+}
+
+// extractHTML fills the bookmark using the first extractor that supports the page or the generic readability extraction
+func (page *Page) extractHTML(ctx context.Context, bookmark *Bookmark) error {
+	for _, extractor := range Extractors {
+		ok, err := extractor.Extract(ctx, page, bookmark)
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("url", bookmark.URL).Msgf("Error extracting bookmark using %T", extractor)
+			continue
+		}
+
+		if ok {
+			page.metadata(bookmark)
+			return nil
+		}
+	}
+
+	article, err := readability.FromReader(bytes.NewReader(page.Body), page.URL)
+	if err != nil {
+		return err
+	}
+
+	bookmark.Title = article.Title
+	bookmark.Content = article.TextContent
+	bookmark.Excerpt = article.Excerpt
+	bookmark.Author = article.Byline
+	bookmark.SiteName = article.SiteName
+	bookmark.Image = article.Image
+	bookmark.Favicon = article.Favicon
+
+	if bookmark.Excerpt == "" {
+		bookmark.Excerpt = excerpt(article.TextContent)
+	}
+
+	page.metadata(bookmark)
+
+	return nil
+}
+
+// excerpt returns the first 260 characters of the text
+func excerpt(text string) string {
+	size := 260
+	if len(text) < size {
+		size = len(text)
+	}
+
+	return text[0:size]
+}
+
+// OEmbedExtractor uses oEmbed discovery for sites that embed videos, photos or rich media
+type OEmbedExtractor struct{}
+
+type oEmbed struct {
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	ProviderName string `json:"provider_name"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// Extract implements the Extractor interface
+func (e *OEmbedExtractor) Extract(ctx context.Context, page *Page, bookmark *Bookmark) (bool, error) {
+	document, err := page.Document()
+	if err != nil {
+		return false, err
+	}
+
+	href, ok := document.Find("link[type='application/json+oembed']").First().Attr("href")
+	if !ok {
+		return false, nil
+	}
+
+	endpoint, err := page.URL.Parse(href)
+	if err != nil {
+		return false, err
+	}
+
+	response, err := DefaultFetcher.Get(ctx, endpoint.String())
+	if err != nil {
+		return false, err
+	}
+
+	defer response.Body.Close()
+
+	var embed oEmbed
+	if err := json.NewDecoder(response.Body).Decode(&embed); err != nil {
+		return false, err
+	}
+
+	if embed.Type == "link" || embed.Title == "" {
+		return false, nil
+	}
+
+	description := page.Meta("og:description", "twitter:description", "description")
+
+	bookmark.Title = embed.Title
+	bookmark.Excerpt = description
+	bookmark.Author = embed.AuthorName
+	bookmark.SiteName = embed.ProviderName
+	bookmark.Image = embed.ThumbnailURL
+
+	content := []string{embed.Title}
+	if embed.AuthorName != "" {
+		content = append(content, "By "+embed.AuthorName)
+	}
+	if embed.ProviderName != "" {
+		content = append(content, "On "+embed.ProviderName)
+	}
+	if description != "" {
+		content = append(content, description)
+	}
+
+	bookmark.Content = strings.Join(content, "\n\n")
+
+	if bookmark.Excerpt == "" {
+		bookmark.Excerpt = excerpt(bookmark.Content)
+	}
+
+	return true, nil
+}
+
+// SelectorRule holds the css selectors used to extract a bookmark from the pages of a domain and its subdomains
+type SelectorRule struct {
+	Domain  string
+	Title   string
+	Content string
+	Excerpt string
+}
+
+// SelectorExtractor extracts bookmarks using css selectors configured per domain
+type SelectorExtractor struct {
+	Rules []SelectorRule
+}
+
+// rule returns the rule with the most specific domain matching the host
+func (e *SelectorExtractor) rule(host string) (SelectorRule, bool) {
+	host = strings.ToLower(host)
+
+	var match SelectorRule
+	for _, rule := range e.Rules {
+		domain := strings.ToLower(rule.Domain)
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			continue
+		}
+
+		if len(domain) > len(match.Domain) {
+			match = rule
+		}
+	}
+
+	return match, match.Domain != ""
+}
+
+// Extract implements the Extractor interface
+func (e *SelectorExtractor) Extract(ctx context.Context, page *Page, bookmark *Bookmark) (bool, error) {
+	rule, ok := e.rule(page.URL.Hostname())
+	if !ok || rule.Content == "" {
+		return false, nil
+	}
+
+	document, err := page.Document()
+	if err != nil {
+		return false, err
+	}
+
+	var content []string
+	document.Find(rule.Content).Each(func(i int, selection *goquery.Selection) {
+		if text := strings.TrimSpace(selection.Text()); text != "" {
+			content = append(content, text)
+		}
+	})
+
+	if len(content) == 0 {
+		return false, nil
+	}
+
+	bookmark.Content = strings.Join(content, "\n\n")
+	bookmark.Title = strings.TrimSpace(document.Find("title").First().Text())
+	bookmark.Excerpt = ""
+
+	if rule.Title != "" {
+		if title := strings.TrimSpace(document.Find(rule.Title).First().Text()); title != "" {
+			bookmark.Title = title
+		}
+	}
+
+	if rule.Excerpt != "" {
+		bookmark.Excerpt = strings.TrimSpace(document.Find(rule.Excerpt).First().Text())
+	}
+
+	if bookmark.Excerpt == "" {
+		bookmark.Excerpt = excerpt(bookmark.Content)
+	}
+
+	return true, nil
+}
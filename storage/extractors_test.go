@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestSelectorExtractor(t *testing.T) {
+	extractor := &SelectorExtractor{Rules: []SelectorRule{
+		{Domain: "example.com", Content: "article"},
+		{Domain: "news.example.com", Title: "h1", Content: ".comment", Excerpt: ".summary"},
+	}}
+
+	pageURL, _ := url.Parse("https://www.news.example.com/item?id=1")
+	page := &Page{URL: pageURL, Body: []byte(`<html><head><title>Page title</title></head><body>
+		<h1>Thread title</h1>
+		<p class="summary">A short summary</p>
+		<div class="comment">First comment</div>
+		<div class="comment">Second comment</div>
+	</body></html>`)}
+
+	bookmark := &Bookmark{}
+
+	ok, err := extractor.Extract(context.Background(), page, bookmark)
+	if err != nil || !ok {
+		t.Fatalf("Expected the page to be extracted: %v", err)
+	}
+
+	if bookmark.Title != "Thread title" || bookmark.Content != "First comment\n\nSecond comment" || bookmark.Excerpt != "A short summary" {
+		t.Fatalf("Unexpected bookmark %+v", bookmark)
+	}
+
+	pageURL, _ = url.Parse("https://example.org/")
+	if ok, _ := extractor.Extract(context.Background(), &Page{URL: pageURL}, bookmark); ok {
+		t.Fatal("Expected a page of another domain not to be extracted")
+	}
+}
+
+func TestOEmbedExtractor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"type": "video", "title": "A video", "author_name": "Someone", "provider_name": "VideoSite"}`))
+	}))
+	defer server.Close()
+
+	pageURL, _ := url.Parse("https://video.example.com/watch?v=1")
+	page := &Page{URL: pageURL, Body: []byte(`<html><head>
+		<link rel="alternate" type="application/json+oembed" href="` + server.URL + `/oembed">
+		<meta property="og:description" content="Watch this video">
+	</head><body></body></html>`)}
+
+	bookmark := &Bookmark{}
+
+	ok, err := (&OEmbedExtractor{}).Extract(context.Background(), page, bookmark)
+	if err != nil || !ok {
+		t.Fatalf("Expected the page to be extracted: %v", err)
+	}
+
+	if bookmark.Title != "A video" || bookmark.Excerpt != "Watch this video" || bookmark.Content != "A video\n\nBy Someone\n\nOn VideoSite\n\nWatch this video" {
+		t.Fatalf("Unexpected bookmark %+v", bookmark)
+	}
+}
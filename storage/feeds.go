@@ -1,8 +1,11 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"sort"
 	"strings"
@@ -34,6 +37,18 @@ type Feed struct {
 	Title        string
 	URL          string
 	Etag         string
+	LastModified string
+	Credentials  FeedCredentials
+	Active       bool
+	RetryAfter   time.Time
+	Image        string
+	FetchContent bool
+	Hub          string
+	HubTopic     string
+	HubSecret    string `json:"-"`
+	HubExpires   time.Time
+	HubMode      string    `json:"-"`
+	HubRequested time.Time `json:"-"`
 	Tags         Tags
 	Items        FeedItems
 }
@@ -48,45 +63,85 @@ func (feed *Feed) Fetch(ctx context.Context) error {
 
 	logger.Info().Msg("Fetching feed")
 
-	client := &http.Client{}
-
-	request, err := http.NewRequest("GET", feed.URL, nil)
+	request, err := http.NewRequestWithContext(ctx, "GET", feed.URL, nil)
 	if err != nil {
 		return err
 	}
 
-	request.Header.Set("User-Agent", defaultUserAgent)
+	feed.Credentials.Apply(request)
 
 	if feed.Etag != "" {
 		request.Header.Set("If-None-Match", feed.Etag)
 		logger = logger.With().Str("If-None-Match", feed.Etag).Logger()
-	} else if !feed.Refreshed.IsZero() {
-		modifiedSince := feed.Refreshed.UTC().Format(time.RFC1123)
-		request.Header.Set("If-Modified-Since", modifiedSince)
-		logger = logger.With().Str("If-Modified-Since", modifiedSince).Logger()
 	}
 
-	response, err := client.Do(request)
+	if feed.LastModified != "" {
+		request.Header.Set("If-Modified-Since", feed.LastModified)
+		logger = logger.With().Str("If-Modified-Since", feed.LastModified).Logger()
+	}
+
+	response, err := DefaultFetcher.Do(request)
+	if httpErr, ok := err.(*HTTPError); ok {
+		switch httpErr.StatusCode {
+		case 410:
+			feed.Active = false
+			logger.Warn().Msg("Feed is gone, deactivating it")
+		case 429, 503:
+			feed.RetryAfter = time.Now().Add(httpErr.RetryAfter)
+			logger.Warn().Time("retry_after", feed.RetryAfter).Msg("Feed is throttled, backing off")
+		}
+	}
 	if err != nil {
-		logger.Warn().Err(err).Int("status_code", response.StatusCode).Msg("Error fetching feed")
+		logger.Warn().Err(err).Msg("Error fetching feed")
 		return err
 	}
 
-	logger.Info().Int("status_code", response.StatusCode).Msg("Successfully fetched feed")
+	defer response.Body.Close()
+
+	logger.Info().Int("status_code", response.StatusCode).Msg("Fetched feed")
 
 	if 304 == response.StatusCode {
 		return nil
 	}
 
-	defer response.Body.Close()
-
-	parsedFeed, err := gofeed.NewParser().Parse(response.Body)
+	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
+		logger.Warn().Err(err).Msg("Error reading feed")
+		return err
+	}
+
+	if err := feed.parse(ctx, body); err != nil {
 		logger.Warn().Err(err).Msg("Unable to parse xml from feed")
 		return err
 	}
 
-	logger.Info().Int("items", len(parsedFeed.Items)).Msg("Found items in Feed")
+	if hub, topic := discoverHub(response.Header, body); hub != "" {
+		feed.Hub = hub
+		feed.HubTopic = topic
+		if feed.HubTopic == "" {
+			feed.HubTopic = feed.URL
+		}
+	}
+
+	if finalURL := response.Request.URL.String(); permanentlyRedirected(response) && finalURL != feed.URL {
+		logger.Info().Str("location", finalURL).Msg("Feed moved permanently")
+		feed.URL = finalURL
+	}
+
+	feed.Etag = response.Header.Get("Etag")
+	feed.LastModified = response.Header.Get("Last-Modified")
+
+	return nil
+}
+
+// parse adds the items from the given rss or atom document that are newer than the last refresh to the feed
+func (feed *Feed) parse(ctx context.Context, body []byte) error {
+	parsedFeed, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Str("id", feed.ID).Int("items", len(parsedFeed.Items)).Msg("Found items in Feed")
 
 	textCleaner := bluemonday.StrictPolicy()
 
@@ -99,6 +154,8 @@ func (feed *Feed) Fetch(ctx context.Context) error {
 			URL:     item.Link,
 		}
 
+		feedItem.parseMedia(item)
+
 		if item.Content != "" {
 			feedItem.Content = textCleaner.Sanitize(item.Content)
 		} else {
@@ -126,13 +183,18 @@ func (feed *Feed) Fetch(ctx context.Context) error {
 		feed.LastAuthored = *parsedFeed.UpdatedParsed
 	}
 
-	feed.Etag = response.Header.Get("Etag")
 	feed.Refreshed = time.Now()
 
 	if feed.Title == "" {
 		feed.Title = parsedFeed.Title
 	}
 
+	if parsedFeed.ITunesExt != nil && parsedFeed.ITunesExt.Image != "" {
+		feed.Image = parsedFeed.ITunesExt.Image
+	} else if parsedFeed.Image != nil {
+		feed.Image = parsedFeed.Image.URL
+	}
+
 	sort.SliceStable(feed.Items, func(i, j int) bool {
 		return feed.Items[i].Date.After(feed.Items[j].Date)
 	})
@@ -140,6 +202,15 @@ func (feed *Feed) Fetch(ctx context.Context) error {
 	return nil
 }
 
+func (feed *Feed) itemIDs() map[string]bool {
+	IDs := map[string]bool{}
+	for _, item := range feed.Items {
+		IDs[item.ID] = true
+	}
+
+	return IDs
+}
+
 // GetItem gets an item by ID from this feed list of items
 func (feed *Feed) GetItem(ID string) *FeedItem {
 	for _, item := range feed.Items {
@@ -171,6 +242,8 @@ type FeedListOptions struct {
 	Search            string
 	Tags              Tags
 	NotRefreshedSince time.Time
+	NotSubscribed     bool
+	Active            bool
 	Limit             int
 	Offset            int
 }
@@ -187,6 +260,14 @@ func (store *Store) FeedList(ctx context.Context, options *FeedListOptions) (*[]
 		query.Where("refreshed < ?", options.NotRefreshedSince)
 	}
 
+	if options.NotSubscribed {
+		query.Where("hub_expires < ?", time.Now())
+	}
+
+	if options.Active {
+		query.Where("active = 1 AND retry_after < ?", time.Now())
+	}
+
 	for _, tag := range options.Tags {
 		if tag == "" {
 			continue
@@ -215,6 +296,10 @@ func (store *Store) FeedList(ctx context.Context, options *FeedListOptions) (*[]
 		return &feeds, 0
 	}
 
+	for _, feed := range feeds {
+		store.credentialsDecrypt(ctx, &feed.Credentials)
+	}
+
 	return &feeds, totalCount
 }
 
@@ -235,6 +320,8 @@ func (store *Store) FeedGet(ctx context.Context, feed *Feed) error {
 		return err
 	}
 
+	store.credentialsDecrypt(ctx, &feed.Credentials)
+
 	return nil
 }
 
@@ -266,14 +353,28 @@ func (store *Store) FeedPersist(ctx context.Context, feed *Feed) error {
 
 	feed.Updated = time.Now()
 
-	// Check if there is already a feed with the same URL in the database
-	store.db.Select(ctx).From("feeds").Columns("id", "created").Where("url = ?", feed.URL).Limit(1).LoadValue(&feed)
+	if err := store.credentialsEncrypt(&feed.Credentials); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("id", feed.ID).Str("url", feed.URL).Msg("Error encrypting feed credentials")
+		return err
+	}
+
+	// Check if there is already a feed with the same URL in the database, a
+	// new feed that matches an existing one keeps the stored active state
+	columns := []string{"id", "created"}
+	if feed.ID == "" {
+		columns = append(columns, "active")
+	}
+	store.db.Select(ctx).From("feeds").Columns(columns...).Where("url = ?", feed.URL).Limit(1).LoadValue(&feed)
+
+	event := EventFeedUpdated
 
 	if feed.ID == "" {
+		event = EventFeedCreated
 		feed.ID = generateUUID()
+		feed.Active = true
 
 		query := store.db.Insert(ctx).InTo("feeds")
-		query.Columns("id", "created", "etag", "items", "last_authored", "refreshed", "tags", "title", "updated", "url")
+		query.Columns("id", "created", "active", "credentials", "etag", "fetch_content", "hub", "hub_expires", "hub_mode", "hub_requested", "hub_secret", "hub_topic", "image", "items", "last_authored", "last_modified", "refreshed", "retry_after", "tags", "title", "updated", "url")
 		query.Record(feed)
 
 		if _, err := query.Exec(); err != nil {
@@ -282,10 +383,22 @@ func (store *Store) FeedPersist(ctx context.Context, feed *Feed) error {
 		}
 	} else {
 		query := store.db.Update(ctx).Table("feeds")
+		query.Set("active", feed.Active)
+		query.Set("credentials", feed.Credentials)
 		query.Set("etag", feed.Etag)
+		query.Set("fetch_content", feed.FetchContent)
+		query.Set("hub", feed.Hub)
+		query.Set("hub_expires", feed.HubExpires)
+		query.Set("hub_mode", feed.HubMode)
+		query.Set("hub_requested", feed.HubRequested)
+		query.Set("hub_secret", feed.HubSecret)
+		query.Set("hub_topic", feed.HubTopic)
+		query.Set("image", feed.Image)
 		query.Set("items", feed.Items)
 		query.Set("last_authored", feed.LastAuthored)
+		query.Set("last_modified", feed.LastModified)
 		query.Set("refreshed", feed.Refreshed)
+		query.Set("retry_after", feed.RetryAfter)
 		query.Set("tags", feed.Tags)
 		query.Set("title", feed.Title)
 		query.Set("updated", feed.Updated)
@@ -298,6 +411,8 @@ func (store *Store) FeedPersist(ctx context.Context, feed *Feed) error {
 		}
 	}
 
+	store.emit(ctx, event, feed.ID, feed.Tags)
+
 	log.Ctx(ctx).Info().Str("id", feed.ID).Str("url", feed.URL).Msg("Persisted feed")
 
 	return nil
@@ -324,22 +439,264 @@ func (store *Store) FeedDelete(ctx context.Context, feed *Feed) error {
 		return err
 	}
 
+	store.emit(ctx, EventFeedDeleted, feed.ID, feed.Tags)
+
 	log.Ctx(ctx).Info().Str("id", feed.ID).Str("url", feed.URL).Msg("Feed deleted")
 
 	return nil
 }
 
-// FeedRefresh fetches the rss feed items and persists those to the database
+// FeedRefresh fetches the rss feed items, applies the rules to new items and persists those to the database
 func (store *Store) FeedRefresh(ctx context.Context, feed *Feed) error {
+	existing := feed.itemIDs()
+
 	if err := feed.Fetch(ctx); err != nil {
+		// Persist the feed to remember it was deactivated or needs to back off
+		if _, ok := err.(*HTTPError); ok {
+			store.FeedPersist(ctx, feed)
+		}
 		return err
 	}
 
-	if err := store.FeedPersist(ctx, feed); err != nil {
+	if err := store.feedUpdate(ctx, feed, existing); err != nil {
 		return err
 	}
 
+	if store.CallbackURL != "" && feed.Hub != "" && time.Now().After(feed.HubExpires) {
+		if err := store.FeedSubscribe(ctx, feed); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("id", feed.ID).Str("hub", feed.Hub).Msg("Error subscribing to hub")
+		}
+	}
+
 	log.Ctx(ctx).Info().Str("id", feed.ID).Str("url", feed.URL).Msg("Feed refreshed")
 
 	return nil
 }
+
+// feedUpdate applies the rules to the items of the feed that are not in existing and persists the feed
+func (store *Store) feedUpdate(ctx context.Context, feed *Feed, existing map[string]bool) error {
+	for _, item := range store.feedApplyRules(ctx, feed, existing) {
+		if _, err := store.feedItemBookmark(ctx, feed, item); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("id", feed.ID).Str("item_id", item.ID).Msg("Error saving feed item as bookmark")
+		}
+	}
+
+	// The feed is persisted once, including the items that were linked to a bookmark
+	if err := store.FeedPersist(ctx, feed); err != nil {
+		return err
+	}
+
+	added := FeedItems{}
+
+	for _, item := range feed.Items {
+		if !existing[item.ID] {
+			// Copies, so fetching the content does not race with the caller
+			copied := *item
+			added = append(added, &copied)
+		}
+	}
+
+	if len(added) > 0 {
+		store.emit(ctx, EventFeedRefreshed, feed.ID, feed.Tags)
+	}
+
+	if feed.FetchContent && len(added) > 0 {
+		go store.feedFetchContent(log.Ctx(ctx).WithContext(context.Background()), feed.ID, added)
+	}
+
+	return nil
+}
+
+// feedFetchContent fetches the full content of the given new items of a feed
+func (store *Store) feedFetchContent(ctx context.Context, ID string, items FeedItems) {
+	fetched := 0
+
+	for _, item := range items {
+		if item.ContentFetched {
+			continue
+		}
+
+		key := ID + "/" + item.ID
+		if _, running := store.fetching.LoadOrStore(key, true); running {
+			continue
+		}
+
+		err := item.FetchContent(ctx)
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("id", ID).Str("item_id", item.ID).Msg("Error fetching content of feed item")
+		}
+
+		if err := store.feedItemContent(ctx, ID, item, err == nil); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("id", ID).Str("item_id", item.ID).Msg("Error storing content of feed item")
+		} else {
+			fetched++
+		}
+
+		store.fetching.Delete(key)
+	}
+
+	log.Ctx(ctx).Info().Str("id", ID).Int("items", fetched).Msg("Fetched content of feed items")
+}
+
+// feedItemContent stores the fetched content of a single item in the items of
+// the feed as they are stored at that moment, so changes to the feed while the
+// content was fetched are kept. Only the ContentFetched flag is stored if the
+// content could not be fetched.
+func (store *Store) feedItemContent(ctx context.Context, ID string, item *FeedItem, fetched bool) error {
+	updated, err := json.Marshal(item.Updated)
+	if err != nil {
+		return err
+	}
+
+	changes := "path || '.ContentFetched', json('true')"
+	params := []interface{}{ID, item.ID}
+	if fetched {
+		changes += ", path || '.Content', ?, path || '.Updated', json(?)"
+		params = append(params, item.Content, string(updated))
+	}
+	params = append(params, ID)
+
+	_, err = store.db.ExecContext(ctx, `WITH item AS (
+			SELECT '$[' || json_each.key || ']' AS path FROM feeds, json_each(feeds.items)
+			WHERE feeds.id = ? AND json_extract(json_each.value, '$.ID') = ?
+		)
+		UPDATE feeds SET items = (SELECT json_set(items, `+changes+`) FROM item)
+		WHERE id = ? AND EXISTS (SELECT 1 FROM item)`, params...)
+
+	return err
+}
+
+// feedApplyRules evaluates all rules against the items of the feed that are not in existing and returns the items to save as a bookmark
+func (store *Store) feedApplyRules(ctx context.Context, feed *Feed, existing map[string]bool) FeedItems {
+	bookmarks := FeedItems{}
+
+	rules, totalCount := store.RuleList(ctx, &RuleListOptions{Limit: -1})
+	if totalCount == 0 {
+		return bookmarks
+	}
+
+	items := FeedItems{}
+
+	for _, item := range feed.Items {
+		if existing[item.ID] {
+			items = append(items, item)
+			continue
+		}
+
+		keep := true
+		bookmark := false
+
+		for _, rule := range *rules {
+			if !rule.Matches(feed, item) {
+				continue
+			}
+
+			log.Ctx(ctx).Debug().Str("id", feed.ID).Str("item_id", item.ID).Str("rule_id", rule.ID).Str("action", rule.Action).Msg("Rule matches feed item")
+
+			if rule.Action == RuleActionBookmark {
+				bookmark = true
+			} else if !rule.Apply(item) {
+				keep = false
+				break
+			}
+		}
+
+		if !keep {
+			continue
+		}
+
+		if bookmark {
+			bookmarks = append(bookmarks, item)
+		}
+
+		items = append(items, item)
+	}
+
+	feed.Items = items
+
+	return bookmarks
+}
+
+// FeedItemBookmark saves an item of the given feed as a bookmark, inheriting the tags of the feed, and links the item to the resulting bookmark
+func (store *Store) FeedItemBookmark(ctx context.Context, feed *Feed, item *FeedItem) (*Bookmark, error) {
+	bookmark, err := store.feedItemBookmark(ctx, feed, item)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.FeedPersist(ctx, feed); err != nil {
+		return nil, err
+	}
+
+	return bookmark, nil
+}
+
+// feedItemBookmark links the item to the bookmark of its url without
+// persisting the feed. An existing bookmark only gets the tags of the feed and
+// item added, a new bookmark is stored right away and its content is fetched
+// in the background.
+func (store *Store) feedItemBookmark(ctx context.Context, feed *Feed, item *FeedItem) (*Bookmark, error) {
+	tags := append(append(Tags{}, feed.Tags...), item.Tags...)
+
+	bookmark := Bookmark{URL: item.URL}
+	if err := store.BookmarkGet(ctx, &bookmark); err == nil {
+		added := false
+
+		for _, tag := range tags {
+			if !hasTag(bookmark.Tags, tag) {
+				bookmark.Tags = append(bookmark.Tags, tag)
+				added = true
+			}
+		}
+
+		if added {
+			if err := store.BookmarkPersist(ctx, &bookmark); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		bookmark = Bookmark{URL: item.URL, Title: item.Title, Tags: tags}
+
+		if err := store.BookmarkPersist(ctx, &bookmark); err != nil {
+			return nil, err
+		}
+
+		go store.bookmarkFetch(log.Ctx(ctx).WithContext(context.Background()), bookmark.ID)
+	}
+
+	item.BookmarkID = bookmark.ID
+	item.Updated = time.Now()
+
+	log.Ctx(ctx).Info().Str("id", feed.ID).Str("item_id", item.ID).Str("bookmark_id", bookmark.ID).Msg("Feed item saved as bookmark")
+
+	return &bookmark, nil
+}
+
+// bookmarkFetch fetches the content of a bookmark that was stored without it,
+// the content is dropped if the bookmark was changed in the meantime
+func (store *Store) bookmarkFetch(ctx context.Context, ID string) {
+	bookmark := Bookmark{ID: ID}
+	if err := store.BookmarkGet(ctx, &bookmark); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("id", ID).Msg("Error loading bookmark to fetch content")
+		return
+	}
+
+	if err := bookmark.Fetch(ctx); err != nil {
+		return
+	}
+
+	if err := store.BookmarkPersist(ctx, &bookmark); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("id", ID).Msg("Error storing fetched content of bookmark")
+	}
+}
+
+// hasTag returns true if tags contains tag
+func hasTag(tags Tags, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
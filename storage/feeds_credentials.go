@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maskedCredential replaces secrets when credentials are encoded to json
+	maskedCredential = "********"
+)
+
+// FeedCredentials holds the authentication and custom request headers used to fetch a Feed.
+// They are stored encrypted with Store.Secret in the database.
+type FeedCredentials struct {
+	Username  string            `json:",omitempty"`
+	Password  string            `json:",omitempty"`
+	Token     string            `json:",omitempty"`
+	UserAgent string            `json:",omitempty"`
+	Headers   map[string]string `json:",omitempty"`
+
+	// encrypted holds the value from the database if it could not be decrypted, so it is not lost on the next update
+	encrypted string
+
+	// ciphertext is the value that is read from and written to the database
+	ciphertext string
+}
+
+// plainCredentials is used to encode FeedCredentials to json without masking the secrets
+type plainCredentials FeedCredentials
+
+func (c *FeedCredentials) isZero() bool {
+	return c.Username == "" && c.Password == "" && c.Token == "" && c.UserAgent == "" && len(c.Headers) == 0
+}
+
+// Apply adds the credentials and custom headers to the request
+func (c *FeedCredentials) Apply(request *http.Request) {
+	if c.UserAgent != "" {
+		request.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	if c.Username != "" || c.Password != "" {
+		request.SetBasicAuth(c.Username, c.Password)
+	}
+
+	if c.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	for name, value := range c.Headers {
+		request.Header.Set(name, value)
+	}
+}
+
+// Value implements the Valuer interface, the credentials must be encrypted with Store.credentialsEncrypt first
+func (c FeedCredentials) Value() (driver.Value, error) {
+	return c.ciphertext, nil
+}
+
+// Scan implements the Scanner interface, the credentials must be decrypted with Store.credentialsDecrypt afterwards
+func (c *FeedCredentials) Scan(value interface{}) error {
+	*c = FeedCredentials{}
+
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		c.ciphertext = v
+	case []byte:
+		c.ciphertext = string(v)
+	default:
+		return fmt.Errorf("Cannot scan %T into FeedCredentials", value)
+	}
+
+	return nil
+}
+
+// FeedCredentialsCount returns the number of feeds that have encrypted credentials stored in the database
+func (store *Store) FeedCredentialsCount(ctx context.Context) int {
+	var count int
+
+	store.db.Select(ctx).From("feeds").Columns("COUNT(*)").Where("credentials IS NOT NULL AND credentials != ''").LoadValue(&count)
+
+	return count
+}
+
+// credentialsEncrypt encrypts the credentials so they can be written to the database
+func (store *Store) credentialsEncrypt(c *FeedCredentials) error {
+	if c.isZero() {
+		c.ciphertext = c.encrypted
+		return nil
+	}
+
+	plaintext, err := json.Marshal(plainCredentials(*c))
+	if err != nil {
+		return err
+	}
+
+	c.ciphertext, err = store.encrypt(plaintext)
+
+	return err
+}
+
+// credentialsDecrypt decrypts the credentials that were read from the
+// database, credentials that cannot be decrypted are kept as they are
+func (store *Store) credentialsDecrypt(ctx context.Context, c *FeedCredentials) {
+	if c.ciphertext == "" {
+		return
+	}
+
+	plaintext, err := store.decrypt(c.ciphertext)
+	if err == nil {
+		err = json.Unmarshal(plaintext, (*plainCredentials)(c))
+	}
+
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Unable to decrypt feed credentials")
+		c.encrypted = c.ciphertext
+	}
+}
+
+// MarshalJSON implements the json.Marshaler interface and masks all secrets
+func (c FeedCredentials) MarshalJSON() ([]byte, error) {
+	masked := plainCredentials(c)
+	masked.Password = mask(c.Password)
+	masked.Token = mask(c.Token)
+
+	if len(c.Headers) > 0 {
+		masked.Headers = map[string]string{}
+		for name, value := range c.Headers {
+			masked.Headers[name] = mask(value)
+		}
+	}
+
+	return json.Marshal(masked)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, masked secrets keep their current value
+func (c *FeedCredentials) UnmarshalJSON(data []byte) error {
+	var updated plainCredentials
+	if err := json.Unmarshal(data, &updated); err != nil {
+		return err
+	}
+
+	if updated.Password == maskedCredential {
+		updated.Password = c.Password
+	}
+
+	if updated.Token == maskedCredential {
+		updated.Token = c.Token
+	}
+
+	for name, value := range updated.Headers {
+		if value == maskedCredential {
+			updated.Headers[name] = c.Headers[name]
+		}
+	}
+
+	updated.encrypted = c.encrypted
+	*c = FeedCredentials(updated)
+
+	return nil
+}
+
+func mask(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	return maskedCredential
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestFeedCredentialsEncryption(t *testing.T) {
+	ctx := context.Background()
+	store := Store{}
+
+	credentials := FeedCredentials{Username: "user", Password: "pass", Headers: map[string]string{"X-Api-Key": "key"}}
+
+	if err := store.credentialsEncrypt(&credentials); err != ErrNoSecret {
+		t.Fatalf("Expected ErrNoSecret but got %v", err)
+	}
+
+	store.Secret = "secret"
+
+	if err := store.credentialsEncrypt(&credentials); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := credentials.Value()
+	if err != nil || value == "" {
+		t.Fatalf("Expected encrypted credentials but got %v: %v", value, err)
+	}
+
+	var scanned FeedCredentials
+	if err := scanned.Scan(value); err != nil {
+		t.Fatal(err)
+	}
+
+	store.credentialsDecrypt(ctx, &scanned)
+
+	if scanned.Username != "user" || scanned.Password != "pass" || scanned.Headers["X-Api-Key"] != "key" {
+		t.Fatalf("Unexpected credentials %+v", scanned)
+	}
+
+	other := Store{Secret: "other secret"}
+
+	var undecryptable FeedCredentials
+	if err := undecryptable.Scan(value); err != nil {
+		t.Fatal(err)
+	}
+
+	other.credentialsDecrypt(ctx, &undecryptable)
+
+	if err := other.credentialsEncrypt(&undecryptable); err != nil {
+		t.Fatal(err)
+	}
+
+	if kept, _ := undecryptable.Value(); kept != value {
+		t.Fatal("Expected credentials that cannot be decrypted to be kept as is")
+	}
+
+	// Clearing decrypted credentials removes them
+	scanned = FeedCredentials{}
+	scanned.Scan(value)
+	store.credentialsDecrypt(ctx, &scanned)
+	scanned.Username, scanned.Password, scanned.Headers = "", "", nil
+
+	if err := store.credentialsEncrypt(&scanned); err != nil {
+		t.Fatal(err)
+	}
+
+	if cleared, _ := scanned.Value(); cleared != "" {
+		t.Fatalf("Expected cleared credentials to be removed but got %v", cleared)
+	}
+}
+
+func TestFeedCredentialsJSON(t *testing.T) {
+	feed := Feed{Credentials: FeedCredentials{Username: "user", Password: "pass", Token: "token", Headers: map[string]string{"X-Api-Key": "key"}}}
+
+	data, err := json.Marshal(feed.Credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != `{"Username":"user","Password":"********","Token":"********","Headers":{"X-Api-Key":"********"}}` {
+		t.Fatalf("Expected secrets to be masked, got %s", data)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Credentials":{"Username":"other","Password":"********","Headers":{"X-Api-Key":"********"}}}`), &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	if feed.Credentials.Username != "other" || feed.Credentials.Password != "pass" || feed.Credentials.Token != "" || feed.Credentials.Headers["X-Api-Key"] != "key" {
+		t.Fatalf("Expected masked secrets to be kept, got %+v", feed.Credentials)
+	}
+}
@@ -1,9 +1,14 @@
 package storage
 
 import (
+	"context"
 	"database/sql/driver"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
 	"github.com/nrocco/qb"
 )
 
@@ -22,13 +27,19 @@ func (i *FeedItems) Scan(value interface{}) error {
 
 // FeedItem represents a FeedItem as part of a Feed
 type FeedItem struct {
-	ID      string
-	Created time.Time
-	Updated time.Time
-	Title   string
-	Date    time.Time
-	URL     string
-	Content string
+	ID             string
+	Created        time.Time
+	Updated        time.Time
+	Title          string
+	Date           time.Time
+	URL            string
+	Content        string
+	Image          string       `json:",omitempty"`
+	Enclosures     []*Enclosure `json:",omitempty"`
+	Read           bool
+	Tags           Tags   `json:",omitempty"`
+	BookmarkID     string `json:",omitempty"`
+	ContentFetched bool   `json:",omitempty"`
 }
 
 // Value implements the Valuer interface
@@ -40,3 +51,113 @@ func (i FeedItem) Value() (driver.Value, error) {
 func (i *FeedItem) Scan(value interface{}) error {
 	return qb.JSONScan(i, value)
 }
+
+// FetchContent replaces the content of the item with the full text of the
+// article it links to, using the same extraction as Bookmark.Fetch
+func (i *FeedItem) FetchContent(ctx context.Context) error {
+	i.ContentFetched = true
+
+	bookmark := Bookmark{URL: i.URL}
+
+	if err := bookmark.Fetch(ctx); err != nil {
+		return err
+	}
+
+	if bookmark.Content != "" {
+		i.Content = bookmark.Content
+		i.Updated = time.Now()
+	}
+
+	return nil
+}
+
+// Enclosure represents a media file attached to a FeedItem, such as a podcast episode or video
+type Enclosure struct {
+	URL      string
+	Type     string `json:",omitempty"`
+	Length   int64  `json:",omitempty"` // Size in bytes
+	Duration int    `json:",omitempty"` // Duration in seconds
+}
+
+// parseMedia extracts the enclosures and image of an item, including iTunes and Media RSS metadata
+func (i *FeedItem) parseMedia(item *gofeed.Item) {
+	duration := 0
+	if item.ITunesExt != nil {
+		duration = parseDuration(item.ITunesExt.Duration)
+		i.Image = item.ITunesExt.Image
+	}
+
+	for _, enclosure := range item.Enclosures {
+		if enclosure.URL == "" {
+			continue
+		}
+
+		length, _ := strconv.ParseInt(enclosure.Length, 10, 64)
+
+		i.Enclosures = append(i.Enclosures, &Enclosure{
+			URL:      enclosure.URL,
+			Type:     enclosure.Type,
+			Length:   length,
+			Duration: duration,
+		})
+	}
+
+	if media, ok := item.Extensions["media"]; ok {
+		i.parseMediaRSS(media)
+
+		for _, group := range media["group"] {
+			i.parseMediaRSS(group.Children)
+		}
+	}
+
+	if i.Image == "" && item.Image != nil {
+		i.Image = item.Image.URL
+	}
+}
+
+func (i *FeedItem) parseMediaRSS(media map[string][]ext.Extension) {
+	for _, content := range media["content"] {
+		url := content.Attrs["url"]
+		if url == "" || i.hasEnclosure(url) {
+			continue
+		}
+
+		length, _ := strconv.ParseInt(content.Attrs["fileSize"], 10, 64)
+
+		i.Enclosures = append(i.Enclosures, &Enclosure{
+			URL:      url,
+			Type:     content.Attrs["type"],
+			Length:   length,
+			Duration: parseDuration(content.Attrs["duration"]),
+		})
+	}
+
+	if thumbnails := media["thumbnail"]; i.Image == "" && len(thumbnails) > 0 {
+		i.Image = thumbnails[0].Attrs["url"]
+	}
+}
+
+func (i *FeedItem) hasEnclosure(url string) bool {
+	for _, enclosure := range i.Enclosures {
+		if enclosure.URL == url {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseDuration converts a duration in the form of SS, MM:SS or HH:MM:SS to seconds
+func parseDuration(value string) int {
+	duration := 0
+
+	for _, part := range strings.Split(strings.TrimSpace(value), ":") {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		duration = duration*60 + number
+	}
+
+	return duration
+}
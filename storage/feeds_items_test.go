@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestParseDuration(t *testing.T) {
+	durations := map[string]int{
+		"":         0,
+		"45":       45,
+		"12:30":    750,
+		"01:02:03": 3723,
+		"1:xx":     0,
+	}
+
+	for value, expected := range durations {
+		if duration := parseDuration(value); duration != expected {
+			t.Fatalf("Expected %q to be %d seconds but got %d", value, expected, duration)
+		}
+	}
+}
+
+func TestFeedItemParseMedia(t *testing.T) {
+	parsedFeed, err := gofeed.NewParser().Parse(strings.NewReader(`<?xml version="1.0"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:media="http://search.yahoo.com/mrss/">
+  <channel>
+    <title>Podcast</title>
+    <item>
+      <title>Episode 1</title>
+      <enclosure url="https://example.com/episode1.mp3" length="1024" type="audio/mpeg"/>
+      <itunes:duration>01:00:00</itunes:duration>
+      <itunes:image href="https://example.com/episode1.jpg"/>
+    </item>
+    <item>
+      <title>Video</title>
+      <media:group>
+        <media:content url="https://example.com/video.mp4" type="video/mp4" duration="90"/>
+        <media:thumbnail url="https://example.com/video.jpg"/>
+      </media:group>
+    </item>
+  </channel>
+</rss>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	podcast := &FeedItem{}
+	podcast.parseMedia(parsedFeed.Items[0])
+
+	if len(podcast.Enclosures) != 1 {
+		t.Fatalf("Expected 1 enclosure but got %d", len(podcast.Enclosures))
+	}
+
+	if enclosure := podcast.Enclosures[0]; enclosure.URL != "https://example.com/episode1.mp3" || enclosure.Type != "audio/mpeg" || enclosure.Length != 1024 || enclosure.Duration != 3600 {
+		t.Fatalf("Unexpected enclosure %+v", enclosure)
+	}
+
+	if podcast.Image != "https://example.com/episode1.jpg" {
+		t.Fatalf("Unexpected image %s", podcast.Image)
+	}
+
+	video := &FeedItem{}
+	video.parseMedia(parsedFeed.Items[1])
+
+	if len(video.Enclosures) != 1 || video.Enclosures[0].Duration != 90 {
+		t.Fatalf("Unexpected enclosures %+v", video.Enclosures)
+	}
+
+	if video.Image != "https://example.com/video.jpg" {
+		t.Fatalf("Unexpected image %s", video.Image)
+	}
+}
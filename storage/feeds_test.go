@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFeedPersistExistingURL(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feed := Feed{URL: "https://example.com/feed.atom"}
+	if err := store.FeedPersist(ctx, &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	if !feed.Active {
+		t.Fatal("Expected a new feed to be active")
+	}
+
+	again := Feed{URL: feed.URL, Title: "Example"}
+	if err := store.FeedPersist(ctx, &again); err != nil {
+		t.Fatal(err)
+	}
+
+	if again.ID != feed.ID || !again.Active {
+		t.Fatalf("Expected the existing active feed %s but got %s active=%v", feed.ID, again.ID, again.Active)
+	}
+
+	feed.Active = false
+	if err := store.FeedPersist(ctx, &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	again = Feed{URL: feed.URL}
+	if err := store.FeedPersist(ctx, &again); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := Feed{ID: feed.ID}
+	if err := store.FeedGet(ctx, &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Active {
+		t.Fatal("Expected the inactive feed to stay inactive")
+	}
+}
+
+func TestFeedPersistCredentials(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feed := Feed{URL: "https://example.com/private.atom", Credentials: FeedCredentials{Token: "token"}}
+	if err := store.FeedPersist(ctx, &feed); err != ErrNoSecret {
+		t.Fatalf("Expected ErrNoSecret but got %v", err)
+	}
+
+	store.Secret = "secret"
+
+	if err := store.FeedPersist(ctx, &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := Feed{ID: feed.ID}
+	if err := store.FeedGet(ctx, &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Credentials.Token != "token" {
+		t.Fatalf("Expected the credentials to be decrypted but got %+v", stored.Credentials)
+	}
+
+	feeds, _ := store.FeedList(ctx, &FeedListOptions{Limit: 10})
+	if len(*feeds) != 1 || (*feeds)[0].Credentials.Token != "token" {
+		t.Fatalf("Expected the listed feed to have decrypted credentials but got %+v", *feeds)
+	}
+}
+
+func TestFeedUpdateEvents(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Article</title></head><body><p>Text</p></body></html>"))
+	}))
+	defer server.Close()
+
+	if err := store.RulePersist(ctx, &Rule{Name: "save", Field: RuleFieldTitle, Operator: RuleOperatorContains, Pattern: "save", Action: RuleActionBookmark}); err != nil {
+		t.Fatal(err)
+	}
+
+	feed := Feed{URL: server.URL + "/feed"}
+	if err := store.FeedPersist(ctx, &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	events, unsubscribe := store.Subscribe()
+	defer unsubscribe()
+
+	feed.Items = FeedItems{
+		{ID: "1", Title: "Please save me", URL: server.URL + "/1"},
+		{ID: "2", Title: "Save me too", URL: server.URL + "/2"},
+	}
+
+	if err := store.feedUpdate(ctx, &feed, map[string]bool{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The content of the new bookmarks is fetched in the background, which
+	// updates them at any time
+	received := []string{}
+	for event := expectEvent(events); event != nil; event = expectEvent(events) {
+		if event.Type != EventBookmarkUpdated {
+			received = append(received, event.Type)
+		}
+	}
+
+	expected := []string{EventBookmarkCreated, EventBookmarkCreated, EventFeedUpdated, EventFeedRefreshed}
+	if strings.Join(received, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected the events %v but got %v", expected, received)
+	}
+
+	stored := Feed{ID: feed.ID}
+	if err := store.FeedGet(ctx, &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range stored.Items {
+		if item.BookmarkID == "" {
+			t.Fatalf("Expected item %s to be linked to a bookmark", item.ID)
+		}
+	}
+}
+
+func TestFeedItemBookmarkExisting(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bookmark := Bookmark{URL: "https://example.com/article", Title: "My title", Content: "Curated", Tags: Tags{"mine"}}
+	if err := store.BookmarkPersist(ctx, &bookmark); err != nil {
+		t.Fatal(err)
+	}
+
+	feed := Feed{URL: "https://example.com/feed", Tags: Tags{"news", "mine"}}
+	item := FeedItem{ID: "1", Title: "Feed title", URL: bookmark.URL}
+
+	linked, err := store.feedItemBookmark(ctx, &feed, &item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if linked.ID != bookmark.ID || item.BookmarkID != bookmark.ID {
+		t.Fatalf("Expected the item to be linked to the existing bookmark but got %s", item.BookmarkID)
+	}
+
+	stored := Bookmark{ID: bookmark.ID}
+	if err := store.BookmarkGet(ctx, &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Title != "My title" || stored.Content != "Curated" || strings.Join(stored.Tags, ",") != "mine,news" {
+		t.Fatalf("Expected only the feed tags to be added but got %q %q %v", stored.Title, stored.Content, stored.Tags)
+	}
+}
+
+func TestFeedFetchContent(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requested := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Article</title></head><body><article><p>The full text of the article that is long enough to be extracted by readability.</p></article></body></html>"))
+	}))
+	defer server.Close()
+
+	feed := Feed{URL: server.URL + "/feed", FetchContent: true, Items: FeedItems{
+		{ID: "old", Title: "Old", URL: server.URL + "/old", Content: "old summary"},
+		{ID: "new", Title: "New", URL: server.URL + "/new", Content: "new summary"},
+	}}
+	if err := store.FeedPersist(ctx, &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	added := FeedItems{{ID: "new", Title: "New", URL: server.URL + "/new", Content: "new summary"}}
+
+	// A refresh that lands while the content is fetched is kept
+	feed.Items = append(FeedItems{{ID: "newer", Title: "Newer", URL: server.URL + "/newer"}}, feed.Items...)
+	if err := store.FeedPersist(ctx, &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	store.feedFetchContent(ctx, feed.ID, added)
+
+	if strings.Join(requested, ",") != "/new" {
+		t.Fatalf("Expected only the new item to be fetched but got %v", requested)
+	}
+
+	stored := Feed{ID: feed.ID}
+	if err := store.FeedGet(ctx, &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stored.Items) != 3 || stored.Items[0].ID != "newer" {
+		t.Fatalf("Expected the items of the refresh to be kept but got %d items", len(stored.Items))
+	}
+
+	if item := stored.Items[2]; !item.ContentFetched || !strings.Contains(item.Content, "full text") {
+		t.Fatalf("Expected the content of the new item to be stored but got %+v", item)
+	}
+
+	if item := stored.Items[1]; item.ContentFetched || item.Content != "old summary" {
+		t.Fatalf("Expected the old item to be left alone but got %+v", item)
+	}
+}
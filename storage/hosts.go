@@ -0,0 +1,342 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// robotsExpiration is how long a downloaded robots.txt is used
+	robotsExpiration = 24 * time.Hour
+
+	// robotsFailureExpiration is how long everything is allowed if the robots.txt could not be downloaded
+	robotsFailureExpiration = 5 * time.Minute
+
+	// hostIdleExpiration is how long the state of a host is kept after it was last used
+	hostIdleExpiration = time.Hour
+
+	// maxHostBackoff limits how long requests to a throttling host are delayed
+	maxHostBackoff = time.Minute
+)
+
+// robotsContextKey marks the requests for a robots.txt, which are not checked against the robots.txt itself
+type robotsContextKey struct{}
+
+var (
+	// ErrDisallowedByRobots is returned when fetching a url that is disallowed by the robots.txt of the host
+	ErrDisallowedByRobots = errors.New("Disallowed by robots.txt")
+)
+
+// HostStats holds the statistics of the requests the Fetcher sent to a single host
+type HostStats struct {
+	Host        string
+	Requests    int
+	Errors      int
+	Throttled   int
+	Disallowed  int
+	Active      int
+	Waiting     int
+	LastRequest time.Time
+	LastStatus  int
+	CrawlDelay  time.Duration
+}
+
+// host limits the number and rate of requests to a single host
+type host struct {
+	mutex sync.Mutex
+	slots chan struct{}
+	next  time.Time
+	stats HostStats
+
+	robotsMutex   sync.Mutex
+	robots        *robots
+	robotsExpires time.Time
+
+	// used is when the host was last returned by Fetcher.host, guarded by Fetcher.mutex
+	used time.Time
+}
+
+// host returns the state of the host of the url
+func (f *Fetcher) host(u *url.URL) *host {
+	name := strings.ToLower(u.Host)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if time.Since(f.evicted) > hostIdleExpiration {
+		f.evict()
+	}
+
+	if h, ok := f.hosts[name]; ok {
+		h.used = time.Now()
+		return h
+	}
+
+	h := &host{stats: HostStats{Host: name}, used: time.Now()}
+	if f.hostConcurrency > 0 {
+		h.slots = make(chan struct{}, f.hostConcurrency)
+	}
+
+	f.hosts[name] = h
+
+	return h
+}
+
+// evict removes the hosts that have not been used for hostIdleExpiration, f.mutex must be held
+func (f *Fetcher) evict() {
+	f.evicted = time.Now()
+
+	for name, h := range f.hosts {
+		if time.Since(h.used) < hostIdleExpiration {
+			continue
+		}
+
+		h.mutex.Lock()
+		idle := h.stats.Active == 0 && h.stats.Waiting == 0 && time.Now().After(h.next)
+		h.mutex.Unlock()
+
+		if idle {
+			delete(f.hosts, name)
+		}
+	}
+}
+
+// Stats returns the statistics of all hosts the Fetcher sent requests to
+func (f *Fetcher) Stats() []HostStats {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	stats := []HostStats{}
+	for _, h := range f.hosts {
+		h.mutex.Lock()
+		stats = append(stats, h.stats)
+		h.mutex.Unlock()
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Host < stats[j].Host
+	})
+
+	return stats
+}
+
+// acquire waits until a request can be sent to the host without exceeding its
+// concurrency and rate, the returned function must be called when the request is done
+func (h *host) acquire(ctx context.Context, interval time.Duration) (func(), error) {
+	h.mutex.Lock()
+	h.stats.Waiting++
+	h.mutex.Unlock()
+
+	defer func() {
+		h.mutex.Lock()
+		h.stats.Waiting--
+		h.mutex.Unlock()
+	}()
+
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			if h.slots != nil {
+				<-h.slots
+			}
+
+			h.mutex.Lock()
+			h.stats.Active--
+			h.mutex.Unlock()
+		})
+	}
+
+	h.mutex.Lock()
+	if h.stats.CrawlDelay > interval {
+		interval = h.stats.CrawlDelay
+	}
+
+	start := time.Now()
+	if h.next.After(start) {
+		start = h.next
+	}
+
+	h.next = start.Add(interval)
+	h.stats.Active++
+	h.mutex.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	return release, nil
+}
+
+// record updates the statistics of the host with the result of a request
+func (h *host) record(response *http.Response, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.stats.Requests++
+	h.stats.LastRequest = time.Now()
+	h.stats.LastStatus = 0
+
+	if response != nil {
+		h.stats.LastStatus = response.StatusCode
+	}
+
+	if httpErr, ok := err.(*HTTPError); ok {
+		h.stats.Errors++
+		h.stats.LastStatus = httpErr.StatusCode
+
+		if httpErr.StatusCode == 429 || httpErr.StatusCode == 503 {
+			h.stats.Throttled++
+
+			backoff := httpErr.RetryAfter
+			if backoff > maxHostBackoff {
+				backoff = maxHostBackoff
+			}
+
+			if next := time.Now().Add(backoff); next.After(h.next) {
+				h.next = next
+			}
+		}
+	} else if err != nil {
+		h.stats.Errors++
+	}
+}
+
+// allowed checks the robots.txt of the host, which is downloaded once a day
+func (f *Fetcher) allowed(ctx context.Context, h *host, u *url.URL) bool {
+	h.robotsMutex.Lock()
+	defer h.robotsMutex.Unlock()
+
+	if h.robots == nil || time.Now().After(h.robotsExpires) {
+		var expiration time.Duration
+		h.robots, expiration = f.fetchRobots(ctx, u)
+		h.robotsExpires = time.Now().Add(expiration)
+
+		h.mutex.Lock()
+		h.stats.CrawlDelay = h.robots.crawlDelay
+		h.mutex.Unlock()
+	}
+
+	if h.robots.allowed(u.RequestURI()) {
+		return true
+	}
+
+	h.mutex.Lock()
+	h.stats.Disallowed++
+	h.mutex.Unlock()
+
+	return false
+}
+
+// fetchRobots downloads and parses the robots.txt of the host of the url and returns how long it can be used,
+// everything is allowed if the host does not have a robots.txt and for a short time if it could not be downloaded
+func (f *Fetcher) fetchRobots(ctx context.Context, u *url.URL) (*robots, time.Duration) {
+	request, err := http.NewRequestWithContext(context.WithValue(ctx, robotsContextKey{}, true), "GET", u.Scheme+"://"+u.Host+"/robots.txt", nil)
+	if err != nil {
+		return &robots{}, robotsFailureExpiration
+	}
+
+	request.Header.Set("User-Agent", f.userAgent)
+
+	// The request goes through the hostTransport, so it is limited like any other request to the host
+	response, err := f.client.Do(request)
+	if err != nil {
+		return &robots{}, robotsFailureExpiration
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode == 429 || response.StatusCode >= 500 {
+		return &robots{}, robotsFailureExpiration
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return &robots{}, robotsExpiration
+	}
+
+	return parseRobots(io.LimitReader(response.Body, 512*1024), f.userAgent), robotsExpiration
+}
+
+// hostTransport applies the limits and the robots.txt of the host to every request
+// of the Fetcher, including the requests that follow a redirect
+type hostTransport struct {
+	fetcher *Fetcher
+	next    http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface, the host is released when the body of the response is closed
+func (t *hostTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	f := t.fetcher
+	h := f.host(request.URL)
+
+	if f.respectRobots && request.Method == "GET" && request.Context().Value(robotsContextKey{}) == nil && !f.allowed(request.Context(), h, request.URL) {
+		return nil, ErrDisallowedByRobots
+	}
+
+	release, err := h.acquire(request.Context(), f.hostInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	cancel := func() {}
+	if f.timeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(request.Context(), f.timeout)
+		request = request.WithContext(ctx)
+	}
+
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		cancel()
+		release()
+		h.record(nil, err)
+		return nil, err
+	}
+
+	if response.StatusCode >= 400 {
+		h.record(response, newHTTPError(response))
+	} else {
+		h.record(response, nil)
+	}
+
+	response.Body = &hostBody{response.Body, func() {
+		cancel()
+		release()
+	}}
+
+	return response, nil
+}
+
+// hostBody releases the host when the body of the response is closed
+type hostBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *hostBody) Close() error {
+	b.release()
+
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRetryAfter is used when a server throttles us without a Retry-After header
+	defaultRetryAfter = time.Hour
+)
+
+var (
+	// ErrBodyTooLarge is returned when reading a response body that exceeds FetcherOptions.MaxBodySize
+	ErrBodyTooLarge = errors.New("Response body too large")
+
+	// DefaultFetcher is the Fetcher used by Bookmark.Fetch, Feed.Fetch and the WebSub subscriptions
+	DefaultFetcher = NewFetcher(FetcherOptions{})
+
+	// redirectHeaders are the request headers that are kept when a request is
+	// redirected to another host, others like the custom headers of feed
+	// credentials are only sent to the host they were meant for
+	redirectHeaders = map[string]bool{"Accept": true, "Accept-Language": true, "User-Agent": true}
+)
+
+// FetcherOptions is used to configure a Fetcher
+type FetcherOptions struct {
+	Timeout            time.Duration
+	Proxy              *url.URL
+	UserAgent          string
+	MaxBodySize        int64
+	InsecureSkipVerify bool
+
+	// HostInterval is the minimum time between two requests to the same host
+	HostInterval time.Duration
+
+	// HostConcurrency is the maximum number of concurrent requests to the same host, 0 for unlimited
+	HostConcurrency int
+
+	// RespectRobots disallows fetching urls that are disallowed by the robots.txt of the host
+	RespectRobots bool
+}
+
+// Fetcher performs http requests to remote servers
+type Fetcher struct {
+	client          *http.Client
+	timeout         time.Duration
+	userAgent       string
+	maxBodySize     int64
+	hostInterval    time.Duration
+	hostConcurrency int
+	respectRobots   bool
+
+	mutex   sync.Mutex
+	hosts   map[string]*host
+	evicted time.Time
+}
+
+// NewFetcher returns a new Fetcher configured with the given options
+func NewFetcher(options FetcherOptions) *Fetcher {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: options.InsecureSkipVerify}
+
+	if options.Proxy != nil {
+		transport.Proxy = http.ProxyURL(options.Proxy)
+	}
+
+	if options.UserAgent == "" {
+		options.UserAgent = defaultUserAgent
+	}
+
+	f := &Fetcher{
+		timeout:         options.Timeout,
+		userAgent:       options.UserAgent,
+		maxBodySize:     options.MaxBodySize,
+		hostInterval:    options.HostInterval,
+		hostConcurrency: options.HostConcurrency,
+		respectRobots:   options.RespectRobots,
+		hosts:           map[string]*host{},
+	}
+
+	// The timeout is applied per request by the hostTransport, so waiting for a busy host does not count
+	f.client = &http.Client{Transport: &hostTransport{f, transport}, CheckRedirect: checkRedirect}
+
+	return f
+}
+
+// Timeout returns the time limit of a single request, 0 means no limit
+func (f *Fetcher) Timeout() time.Duration {
+	return f.timeout
+}
+
+// Get fetches the given url
+func (f *Fetcher) Get(ctx context.Context, url string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.Do(request)
+}
+
+// Do sends the request and returns the response if the status code is 2xx or 304, otherwise a *HTTPError is returned.
+// Reading the body of the response fails with ErrBodyTooLarge if it exceeds the configured maximum size.
+// Requests to the same host, including redirects, are limited to the configured rate and concurrency until the body of the response is closed.
+func (f *Fetcher) Do(request *http.Request) (*http.Response, error) {
+	if request.Header.Get("User-Agent") == "" {
+		request.Header.Set("User-Agent", f.userAgent)
+	}
+
+	response, err := f.client.Do(request)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok && urlErr.Err == ErrDisallowedByRobots {
+			return nil, ErrDisallowedByRobots
+		}
+		return nil, err
+	}
+
+	if (response.StatusCode < 200 || response.StatusCode > 299) && response.StatusCode != 304 {
+		response.Body.Close()
+		return nil, newHTTPError(response)
+	}
+
+	if f.maxBodySize > 0 {
+		response.Body = &maxBytesReader{response.Body, f.maxBodySize}
+	}
+
+	return response, nil
+}
+
+// checkRedirect follows at most 10 redirects and removes all but the
+// redirectHeaders from requests that are redirected to another host
+func checkRedirect(request *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("Stopped after 10 redirects")
+	}
+
+	if request.URL.Host != via[0].URL.Host {
+		for name := range request.Header {
+			if !redirectHeaders[name] {
+				request.Header.Del(name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// maxBytesReader fails with ErrBodyTooLarge when more than remaining bytes are read
+type maxBytesReader struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (r *maxBytesReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+
+	n, err := r.ReadCloser.Read(p)
+
+	if int64(n) > r.remaining {
+		n = int(r.remaining)
+		r.remaining = 0
+		return n, ErrBodyTooLarge
+	}
+
+	r.remaining -= int64(n)
+
+	return n, err
+}
+
+// permanentlyRedirected returns true if the response was the result of only permanent redirects
+func permanentlyRedirected(response *http.Response) bool {
+	redirected := false
+
+	for request := response.Request; request.Response != nil; request = request.Response.Request {
+		if code := request.Response.StatusCode; code != 301 && code != 308 {
+			return false
+		}
+		redirected = true
+	}
+
+	return redirected
+}
+
+// HTTPError is returned when a remote server responds with a non 2xx status code
+type HTTPError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s responded with %s", e.URL, e.Status)
+}
+
+func newHTTPError(response *http.Response) *HTTPError {
+	httpErr := &HTTPError{
+		URL:        response.Request.URL.String(),
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+	}
+
+	if response.StatusCode == 429 || response.StatusCode == 503 {
+		httpErr.RetryAfter = parseRetryAfter(response.Header.Get("Retry-After"), time.Now())
+	}
+
+	return httpErr
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a number of seconds or a http date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if date.After(now) {
+			return date.Sub(now)
+		}
+		return 0
+	}
+
+	return defaultRetryAfter
+}
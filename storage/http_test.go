@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	values := map[string]time.Duration{
+		"120":                           2 * time.Minute,
+		"0":                             0,
+		"Thu, 01 Jul 2021 12:30:00 GMT": 30 * time.Minute,
+		"Thu, 01 Jul 2021 11:00:00 GMT": 0,
+		"":                              defaultRetryAfter,
+		"soon":                          defaultRetryAfter,
+	}
+
+	for value, expected := range values {
+		if retryAfter := parseRetryAfter(value, now); retryAfter != expected {
+			t.Fatalf("Expected %q to be %v but got %v", value, expected, retryAfter)
+		}
+	}
+}
+
+func TestFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/permanent", 308)
+		case "/permanent":
+			http.Redirect(w, r, "/large", 301)
+		case "/found":
+			http.Redirect(w, r, "/large", 302)
+		case "/large":
+			w.Write([]byte(strings.Repeat("x", 100)))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(FetcherOptions{MaxBodySize: 50})
+
+	if _, err := fetcher.Get(context.Background(), server.URL+"/missing"); err == nil {
+		t.Fatal("Expected a 404 to fail")
+	} else if httpErr, ok := err.(*HTTPError); !ok || httpErr.StatusCode != 404 {
+		t.Fatalf("Expected a HTTPError but got %v", err)
+	}
+
+	response, err := fetcher.Get(context.Background(), server.URL+"/moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if !permanentlyRedirected(response) {
+		t.Fatal("Expected the response to be permanently redirected")
+	}
+
+	if _, err := ioutil.ReadAll(response.Body); err != ErrBodyTooLarge {
+		t.Fatalf("Expected ErrBodyTooLarge but got %v", err)
+	}
+
+	response, err = fetcher.Get(context.Background(), server.URL+"/found")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if permanentlyRedirected(response) {
+		t.Fatal("Expected the response not to be permanently redirected")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := fetcher.Get(ctx, server.URL+"/large"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled but got %v", err)
+	}
+}
+
+func TestFetcherRedirectHeaders(t *testing.T) {
+	received := http.Header{}
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/same":
+			http.Redirect(w, r, "/target", 302)
+		case "/other":
+			http.Redirect(w, r, other.URL+"/target", 302)
+		default:
+			received = r.Header.Clone()
+		}
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(FetcherOptions{UserAgent: "test"})
+
+	for _, path := range []string{"/same", "/other"} {
+		request, _ := http.NewRequest("GET", server.URL+path, nil)
+		(&FeedCredentials{Token: "token", Headers: map[string]string{"X-Api-Key": "key"}}).Apply(request)
+
+		response, err := fetcher.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+
+		if path == "/same" && (received.Get("X-Api-Key") != "key" || received.Get("Authorization") == "") {
+			t.Fatalf("Expected the credentials to be kept on the same host but got %v", received)
+		}
+
+		if path == "/other" && (received.Get("X-Api-Key") != "" || received.Get("Authorization") != "") {
+			t.Fatalf("Expected the credentials to be removed for another host but got %v", received)
+		}
+
+		if received.Get("User-Agent") != "test" {
+			t.Fatalf("Expected the user agent to be kept but got %q", received.Get("User-Agent"))
+		}
+	}
+}
+
+func TestFetcherHostLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+		case "/busy":
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(429)
+		default:
+			w.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(FetcherOptions{HostInterval: 50 * time.Millisecond, HostConcurrency: 1, RespectRobots: true})
+
+	started := time.Now()
+	for i := 0; i < 3; i++ {
+		response, err := fetcher.Get(context.Background(), server.URL+"/public")
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+	}
+
+	if elapsed := time.Since(started); elapsed < 100*time.Millisecond {
+		t.Fatalf("Expected the requests to be spread over at least 100ms but took %s", elapsed)
+	}
+
+	if _, err := fetcher.Get(context.Background(), server.URL+"/private/page"); err != ErrDisallowedByRobots {
+		t.Fatalf("Expected ErrDisallowedByRobots but got %v", err)
+	}
+
+	if _, err := fetcher.Get(context.Background(), server.URL+"/busy"); err == nil {
+		t.Fatal("Expected a throttled request to fail")
+	}
+
+	response, err := fetcher.Get(context.Background(), server.URL+"/public")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The host is busy until the body of the response is closed
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := fetcher.Get(ctx, server.URL+"/public"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the concurrency limit to block the request but got %v", err)
+	}
+
+	response.Body.Close()
+
+	// The robots.txt is requested through the limits of the host as well
+	stats := fetcher.Stats()
+	if len(stats) != 1 || stats[0].Requests != 6 || stats[0].Errors != 1 || stats[0].Throttled != 1 || stats[0].Disallowed != 1 || stats[0].Active != 0 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestFetcherRobotsRedirects(t *testing.T) {
+	robotsStatus := 500
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			if robotsStatus != 200 {
+				w.WriteHeader(robotsStatus)
+				return
+			}
+			w.Write([]byte("User-agent: *\nDisallow: /private\nDisallow: /*?session=\n"))
+		case "/redirect":
+			http.Redirect(w, r, "/private/page", 302)
+		default:
+			w.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(FetcherOptions{RespectRobots: true})
+
+	response, err := fetcher.Get(context.Background(), server.URL+"/private/page")
+	if err != nil {
+		t.Fatalf("Expected everything to be allowed while the robots.txt fails but got %v", err)
+	}
+	response.Body.Close()
+
+	h := fetcher.host(response.Request.URL)
+	if expires := time.Until(h.robotsExpires); expires > robotsFailureExpiration {
+		t.Fatalf("Expected a failed robots.txt to be cached briefly but it expires in %s", expires)
+	}
+
+	robotsStatus = 200
+	h.robotsExpires = time.Now()
+
+	if _, err := fetcher.Get(context.Background(), server.URL+"/redirect"); err != ErrDisallowedByRobots {
+		t.Fatalf("Expected a redirect to a disallowed url to fail with ErrDisallowedByRobots but got %v", err)
+	}
+
+	if _, err := fetcher.Get(context.Background(), server.URL+"/page?session=1"); err != ErrDisallowedByRobots {
+		t.Fatalf("Expected the query to be matched against the robots.txt but got %v", err)
+	}
+}
+
+func TestFetcherEvictHosts(t *testing.T) {
+	fetcher := NewFetcher(FetcherOptions{})
+
+	idle := fetcher.host(&url.URL{Host: "idle.example.com"})
+	idle.used = time.Now().Add(-2 * hostIdleExpiration)
+
+	busy := fetcher.host(&url.URL{Host: "busy.example.com"})
+	busy.used = time.Now().Add(-2 * hostIdleExpiration)
+	busy.stats.Active = 1
+
+	fetcher.evicted = time.Time{}
+	fetcher.host(&url.URL{Host: "other.example.com"})
+
+	if _, ok := fetcher.hosts["idle.example.com"]; ok {
+		t.Fatal("Expected the idle host to be evicted")
+	}
+
+	if _, ok := fetcher.hosts["busy.example.com"]; !ok {
+		t.Fatal("Expected the busy host to be kept")
+	}
+}
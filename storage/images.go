@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif" // registers the gif format for image.Decode
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// imageMaxPixels is the maximum width times height of an image that is
+	// decoded, a small file can describe a huge image that exhausts the memory
+	imageMaxPixels = 50 * 1000 * 1000
+)
+
+var (
+	// ErrNoImageKey is returned if the Image does not have a ID or URL
+	ErrNoImageKey = errors.New("Missing Image.ID or Image.URL")
+
+	// ErrImageNotReferenced is returned if an image url is not used by any bookmark or feed
+	ErrImageNotReferenced = errors.New("Image is not referenced by a bookmark or feed")
+
+	// ErrImageTooLarge is returned if an image is larger than Store.ImageMaxSize
+	ErrImageTooLarge = errors.New("Image is too large")
+
+	// ErrNotAnImage is returned if the downloaded file is not an image
+	ErrNotAnImage = errors.New("Not an image")
+)
+
+// imageReferences is the sql condition matching images used by bookmarks, feeds and feed items
+const imageReferences = `(url IN (SELECT image FROM bookmarks UNION SELECT favicon FROM bookmarks UNION SELECT image FROM feeds)
+	OR EXISTS (SELECT 1 FROM feeds, json_each(feeds.items) WHERE json_extract(json_each.value, '$.Image') = images.url))`
+
+// Image is a cached and resized copy of a remote favicon or preview image
+type Image struct {
+	ID          string
+	URL         string
+	Created     time.Time
+	Accessed    time.Time
+	ContentType string
+	Width       int
+	Height      int
+	Size        int
+	Data        []byte `json:"-"`
+}
+
+// ImageHash returns the ID of the cached image of the given url
+func ImageHash(url string) string {
+	sum := sha256.Sum256([]byte(url))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// ImageGet finds a cached image by ID or URL and marks it as accessed
+func (store *Store) ImageGet(ctx context.Context, cached *Image) error {
+	if cached.ID == "" && cached.URL == "" {
+		return ErrNoImageKey
+	}
+
+	if cached.ID == "" {
+		cached.ID = ImageHash(cached.URL)
+	}
+
+	query := store.db.Select(ctx).From("images")
+	query.Where("id = ?", cached.ID)
+	query.Limit(1)
+
+	if err := query.LoadValue(&cached); err != nil {
+		return err
+	}
+
+	if cached.Accessed.Before(time.Now().Add(-1 * time.Hour)) {
+		cached.Accessed = time.Now()
+		store.db.Update(ctx).Table("images").Set("accessed", cached.Accessed).Where("id = ?", cached.ID).Exec()
+	}
+
+	return nil
+}
+
+// ImageCache returns the cached copy of the image at url, downloading and
+// resizing it first if it is not in the cache yet. Only images referenced by
+// a bookmark or feed are cached.
+func (store *Store) ImageCache(ctx context.Context, url string) (*Image, error) {
+	cached := Image{URL: url}
+
+	if err := store.ImageGet(ctx, &cached); err == nil {
+		return &cached, nil
+	}
+
+	if !store.imageReferenced(ctx, url) {
+		return nil, ErrImageNotReferenced
+	}
+
+	if err := store.imageDownload(ctx, &cached); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("url", url).Msg("Error caching image")
+		return nil, err
+	}
+
+	cached.Created = time.Now()
+	cached.Accessed = cached.Created
+
+	query := store.db.Insert(ctx).InTo("images")
+	query.Columns("id", "url", "created", "accessed", "content_type", "width", "height", "size", "data")
+	query.Record(cached)
+
+	if _, err := query.Exec(); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("url", url).Msg("Error persisting image")
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().Str("id", cached.ID).Str("url", url).Int("size", cached.Size).Msg("Cached image")
+
+	return &cached, nil
+}
+
+// ImageCleanup removes images that are no longer referenced by a bookmark or
+// feed, or have not been accessed for longer than Store.ImageMaxAge
+func (store *Store) ImageCleanup(ctx context.Context) (int64, error) {
+	query := store.db.Delete(ctx).From("images")
+	query.Where("NOT "+imageReferences+" OR accessed < ?", time.Now().Add(-1*store.ImageMaxAge))
+
+	result, err := query.Exec()
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Error cleaning up images")
+		return 0, err
+	}
+
+	removed, _ := result.RowsAffected()
+
+	log.Ctx(ctx).Info().Int64("removed", removed).Msg("Cleaned up images")
+
+	return removed, nil
+}
+
+// imageReferenced checks if the url is used by a bookmark, feed or feed item
+func (store *Store) imageReferenced(ctx context.Context, url string) bool {
+	referenced := 0
+
+	store.db.Select(ctx).From("bookmarks").Columns("COUNT(id)").Where("image = ? OR favicon = ?", url, url).LoadValue(&referenced)
+	if referenced > 0 {
+		return true
+	}
+
+	query := store.db.Select(ctx).From("feeds").Columns("COUNT(id)")
+	query.Where("image = ? OR EXISTS (SELECT 1 FROM json_each(feeds.items) WHERE json_extract(json_each.value, '$.Image') = ?)", url, url)
+	query.LoadValue(&referenced)
+
+	return referenced > 0
+}
+
+// imageDownload fetches the image and scales it down to Store.ImageMaxDimension
+func (store *Store) imageDownload(ctx context.Context, cached *Image) error {
+	response, err := DefaultFetcher.Get(ctx, cached.URL)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(&maxBytesReader{response.Body, store.ImageMaxSize})
+	if err == ErrBodyTooLarge {
+		return ErrImageTooLarge
+	} else if err != nil {
+		return err
+	}
+
+	cached.ContentType = http.DetectContentType(data)
+	if !strings.HasPrefix(cached.ContentType, "image/") {
+		if contentType := response.Header.Get("Content-Type"); strings.HasPrefix(contentType, "image/") && !strings.HasPrefix(contentType, "image/svg") {
+			cached.ContentType = contentType
+		} else {
+			return ErrNotAnImage
+		}
+	}
+
+	cached.Data = data
+	cached.Size = len(data)
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		// Formats like ico and webp are cached as they are
+		return nil
+	}
+
+	if int64(config.Width)*int64(config.Height) > imageMaxPixels {
+		return ErrImageTooLarge
+	}
+
+	picture, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+
+	bounds := picture.Bounds()
+	cached.Width, cached.Height = bounds.Dx(), bounds.Dy()
+
+	if cached.Width <= store.ImageMaxDimension && cached.Height <= store.ImageMaxDimension {
+		return nil
+	}
+
+	picture = resize(picture, store.ImageMaxDimension)
+	bounds = picture.Bounds()
+	cached.Width, cached.Height = bounds.Dx(), bounds.Dy()
+
+	var buffer bytes.Buffer
+	if format == "jpeg" {
+		cached.ContentType = "image/jpeg"
+		err = jpeg.Encode(&buffer, picture, &jpeg.Options{Quality: 85})
+	} else {
+		cached.ContentType = "image/png"
+		err = png.Encode(&buffer, picture)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	cached.Data = buffer.Bytes()
+	cached.Size = buffer.Len()
+
+	return nil
+}
+
+// resize scales the image down so that its largest side equals maxDimension,
+// averaging the source pixels covered by each pixel of the result
+func resize(picture image.Image, maxDimension int) image.Image {
+	bounds := picture.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width >= height {
+		width, height = maxDimension, height*maxDimension/width
+	} else {
+		width, height = width*maxDimension/height, maxDimension
+	}
+
+	if width < 1 {
+		width = 1
+	}
+
+	if height < 1 {
+		height = 1
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/height
+
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/width
+
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := picture.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					count++
+				}
+			}
+
+			if count > 0 {
+				resized.Set(x, y, color.RGBA64{uint16(r / count), uint16(g / count), uint16(b / count), uint16(a / count)})
+			}
+		}
+	}
+
+	return resized
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	resized := resize(image.NewRGBA(image.Rect(0, 0, 1000, 250)), 200)
+
+	if bounds := resized.Bounds(); bounds.Dx() != 200 || bounds.Dy() != 50 {
+		t.Fatalf("Expected a 200x50 image but got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+
+	resized = resize(image.NewRGBA(image.Rect(0, 0, 10, 4000)), 100)
+
+	if bounds := resized.Bounds(); bounds.Dx() != 1 || bounds.Dy() != 100 {
+		t.Fatalf("Expected a 1x100 image but got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+}
+
+func TestImageCache(t *testing.T) {
+	var buffer bytes.Buffer
+	png.Encode(&buffer, image.NewRGBA(image.Rect(0, 0, 800, 400)))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page.html" {
+			w.Write([]byte("<html></html>"))
+			return
+		}
+		if r.URL.Path == "/bomb.gif" {
+			// A gif header describing an image of 65535 by 65535 pixels
+			w.Write([]byte("GIF89a\xff\xff\xff\xff\x00\x00\x00;"))
+			return
+		}
+		w.Write(buffer.Bytes())
+	}))
+	defer server.Close()
+
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.ImageCache(ctx, server.URL+"/image.png"); err != ErrImageNotReferenced {
+		t.Fatalf("Expected ErrImageNotReferenced but got %v", err)
+	}
+
+	bookmark := Bookmark{URL: server.URL + "/page.html", Image: server.URL + "/image.png", Favicon: server.URL + "/page.html"}
+	if err := store.BookmarkPersist(ctx, &bookmark); err != nil {
+		t.Fatal(err)
+	}
+
+	cached, err := store.ImageCache(ctx, bookmark.Image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cached.ID != ImageHash(bookmark.Image) || cached.ContentType != "image/png" || cached.Width != 640 || cached.Height != 320 {
+		t.Fatalf("Unexpected image %+v", cached)
+	}
+
+	if _, err := store.ImageCache(ctx, bookmark.Favicon); err != ErrNotAnImage {
+		t.Fatalf("Expected ErrNotAnImage but got %v", err)
+	}
+
+	bomb := Bookmark{URL: server.URL + "/other.html", Image: server.URL + "/bomb.gif"}
+	if err := store.BookmarkPersist(ctx, &bomb); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.ImageCache(ctx, bomb.Image); err != ErrImageTooLarge {
+		t.Fatalf("Expected ErrImageTooLarge but got %v", err)
+	}
+
+	if err := store.BookmarkDelete(ctx, &bomb); err != nil {
+		t.Fatal(err)
+	}
+
+	if removed, err := store.ImageCleanup(ctx); err != nil || removed != 0 {
+		t.Fatalf("Expected no images to be removed but got %d: %v", removed, err)
+	}
+
+	if err := store.BookmarkDelete(ctx, &bookmark); err != nil {
+		t.Fatal(err)
+	}
+
+	if removed, err := store.ImageCleanup(ctx); err != nil || removed != 1 {
+		t.Fatalf("Expected the image to be removed but got %d: %v", removed, err)
+	}
+}
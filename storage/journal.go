@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"text/template"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// JournalTag is the tag of the daily journal thoughts
+	JournalTag = "journal"
+
+	// DefaultJournalTemplate is used to create journal thoughts if Store.JournalTemplate is empty
+	DefaultJournalTemplate = "# {{ .Date.Format \"Monday, 2 January 2006\" }}\n\n"
+
+	journalDateFormat = "2006-01-02"
+)
+
+// JournalDay is a day that has a journal thought
+type JournalDay struct {
+	Date  string
+	ID    string
+	Title string
+}
+
+// ParseJournalDate parses a date in the YYYY-MM-DD format in the local timezone
+func ParseJournalDate(value string) (time.Time, error) {
+	return time.ParseInLocation(journalDateFormat, value, time.Local)
+}
+
+// ThoughtJournal returns the journal thought of the day, which is created
+// using Store.JournalTemplate if it does not exist yet
+func (store *Store) ThoughtJournal(ctx context.Context, day time.Time) (*Thought, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+
+	// Concurrent requests for the same day must not both create a journal thought
+	store.journal.Lock()
+	defer store.journal.Unlock()
+
+	thoughts, _ := store.ThoughtList(ctx, &ThoughtListOptions{
+		Tags:          Tags{JournalTag},
+		CreatedSince:  start,
+		CreatedBefore: start.AddDate(0, 0, 1),
+		Limit:         1,
+	})
+
+	if len(*thoughts) > 0 {
+		return (*thoughts)[0], nil
+	}
+
+	content, err := store.journalContent(start)
+	if err != nil {
+		return nil, err
+	}
+
+	thought := Thought{
+		Created: start,
+		Content: content,
+		Tags:    Tags{JournalTag},
+	}
+
+	if err := store.ThoughtPersist(ctx, &thought); err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().Str("id", thought.ID).Str("date", start.Format(journalDateFormat)).Msg("Created journal thought")
+
+	return &thought, nil
+}
+
+// ThoughtJournalCalendar returns the days between since and before that have a journal thought
+func (store *Store) ThoughtJournalCalendar(ctx context.Context, since, before time.Time) []*JournalDay {
+	thoughts, _ := store.ThoughtList(ctx, &ThoughtListOptions{
+		Tags:          Tags{JournalTag},
+		CreatedSince:  since,
+		CreatedBefore: before,
+		Limit:         -1,
+	})
+
+	days := []*JournalDay{}
+	seen := map[string]bool{}
+
+	// Thoughts are listed newest first, the calendar is in chronological order
+	for i := len(*thoughts) - 1; i >= 0; i-- {
+		thought := (*thoughts)[i]
+		date := thought.Created.In(time.Local).Format(journalDateFormat)
+
+		if !seen[date] {
+			seen[date] = true
+			days = append(days, &JournalDay{Date: date, ID: thought.ID, Title: thought.Title()})
+		}
+	}
+
+	return days
+}
+
+// ThoughtJournalAdjacent returns the closest days before and after the day that have a journal thought
+func (store *Store) ThoughtJournalAdjacent(ctx context.Context, day time.Time) (previous string, next string) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	tag := "EXISTS (SELECT 1 FROM json_each(thoughts.tags) where json_each.value = ?)"
+
+	thought := Thought{}
+
+	query := store.db.Select(ctx).From("thoughts").Columns("created")
+	query.Where(tag, JournalTag).Where("created < ?", start)
+	query.OrderBy("created", "DESC").Limit(1)
+
+	if err := query.LoadValue(&thought); err == nil {
+		previous = thought.Created.In(time.Local).Format(journalDateFormat)
+	}
+
+	query = store.db.Select(ctx).From("thoughts").Columns("created")
+	query.Where(tag, JournalTag).Where("created >= ?", start.AddDate(0, 0, 1))
+	query.OrderBy("created", "ASC").Limit(1)
+
+	if err := query.LoadValue(&thought); err == nil {
+		next = thought.Created.In(time.Local).Format(journalDateFormat)
+	}
+
+	return previous, next
+}
+
+func (store *Store) journalContent(day time.Time) (string, error) {
+	text := store.JournalTemplate
+	if text == "" {
+		text = DefaultJournalTemplate
+	}
+
+	tmpl, err := template.New("journal").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, struct{ Date time.Time }{day}); err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
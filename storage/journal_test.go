@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThoughtJournal(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.JournalTemplate = "# {{ .Date.Format \"2006-01-02\" }}\n"
+
+	day, _ := ParseJournalDate("2026-10-18")
+
+	journal, err := store.ThoughtJournal(ctx, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if journal.Content != "# 2026-10-18\n" || journal.Tags[0] != JournalTag {
+		t.Fatalf("Unexpected journal thought %+v", journal)
+	}
+
+	again, err := store.ThoughtJournal(ctx, day.Add(15*time.Hour))
+	if err != nil || again.ID != journal.ID {
+		t.Fatalf("Expected the existing journal thought to be returned but got %+v: %v", again, err)
+	}
+
+	for _, date := range []string{"2026-10-01", "2026-10-25", "2026-11-02"} {
+		day, _ := ParseJournalDate(date)
+		if _, err := store.ThoughtJournal(ctx, day); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous, next := store.ThoughtJournalAdjacent(ctx, day)
+	if previous != "2026-10-01" || next != "2026-10-25" {
+		t.Fatalf("Expected 2026-10-01 and 2026-10-25 but got %q and %q", previous, next)
+	}
+
+	month, _ := ParseJournalDate("2026-10-01")
+	days := store.ThoughtJournalCalendar(ctx, month, month.AddDate(0, 1, 0))
+
+	if len(days) != 3 || days[0].Date != "2026-10-01" || days[1].ID != journal.ID || days[2].Date != "2026-10-25" {
+		t.Fatalf("Unexpected calendar %+v", days)
+	}
+}
+
+func TestThoughtJournalConcurrent(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day, _ := ParseJournalDate("2026-10-19")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.ThoughtJournal(ctx, day)
+		}()
+	}
+	wg.Wait()
+
+	if days := store.ThoughtJournalCalendar(ctx, day, day.AddDate(0, 0, 1)); len(days) != 1 {
+		t.Fatalf("Expected 1 journal day but got %d", len(days))
+	}
+
+	thoughts, count := store.ThoughtList(ctx, &ThoughtListOptions{Tags: Tags{JournalTag}, Limit: -1})
+	if count != 1 || len(*thoughts) != 1 {
+		t.Fatalf("Expected a single journal thought but got %d", count)
+	}
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// LinkTypeThought is a [[...]] reference to the ID or title of another thought
+	LinkTypeThought = "thought"
+
+	// LinkTypeURL is an url, which links to a bookmark if the url is bookmarked
+	LinkTypeURL = "url"
+)
+
+var (
+	wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]|]+)(\|[^\[\]]*)?\]\]`)
+	urlPattern      = regexp.MustCompile(`https?://[^\s<>()\[\]"'` + "`" + `]+`)
+)
+
+// Link is a reference from a thought to another thought or to an url
+type Link struct {
+	ThoughtID string
+	Type      string
+	Target    string
+
+	// TargetID and Title are the ID and title of the thought or bookmark the
+	// link points to, or of the thought the link originates from for backlinks
+	TargetID string `db:"-"`
+	Title    string `db:"-"`
+}
+
+// Links holds the outgoing links and backlinks of a thought or bookmark
+type Links struct {
+	Outgoing  []*Link
+	Backlinks []*Link
+}
+
+// Links parses the [[...]] references and urls in the content of the thought
+func (thought *Thought) Links() []*Link {
+	links := []*Link{}
+	seen := map[string]bool{}
+
+	add := func(kind, target string) {
+		if target == "" || seen[kind+target] {
+			return
+		}
+
+		seen[kind+target] = true
+		links = append(links, &Link{ThoughtID: thought.ID, Type: kind, Target: target})
+	}
+
+	for _, match := range wikiLinkPattern.FindAllStringSubmatch(thought.Content, -1) {
+		add(LinkTypeThought, strings.TrimSpace(match[1]))
+	}
+
+	for _, match := range urlPattern.FindAllString(thought.Content, -1) {
+		add(LinkTypeURL, strings.TrimRight(match, ".,;:!?"))
+	}
+
+	return links
+}
+
+// linksPersist replaces the links of the thought in the index
+func (store *Store) linksPersist(ctx context.Context, thought *Thought) error {
+	if _, err := store.db.Delete(ctx).From("links").Where("thought_id = ?", thought.ID).Exec(); err != nil {
+		return err
+	}
+
+	for _, link := range thought.Links() {
+		query := store.db.Insert(ctx).InTo("links")
+		query.Columns("thought_id", "type", "target")
+		query.Record(link)
+
+		if _, err := query.Exec(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// likeEscape escapes the wildcards of a LIKE pattern, to be used with ESCAPE '\'
+func likeEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// linksReindex rebuilds the link index of all thoughts
+func (store *Store) linksReindex(ctx context.Context) error {
+	thoughts := []*Thought{}
+	if _, err := store.db.Select(ctx).From("thoughts").Columns("id", "content").Load(&thoughts); err != nil {
+		return err
+	}
+
+	for _, thought := range thoughts {
+		if err := store.linksPersist(ctx, thought); err != nil {
+			return err
+		}
+	}
+
+	log.Ctx(ctx).Info().Int("thoughts", len(thoughts)).Msg("Reindexed links of thoughts")
+
+	return nil
+}
+
+// ThoughtLinks returns the links of the thought to other thoughts and bookmarks and the thoughts linking to it
+func (store *Store) ThoughtLinks(ctx context.Context, thought *Thought) (*Links, error) {
+	links := Links{Outgoing: []*Link{}}
+
+	query := store.db.Select(ctx).From("links")
+	query.Columns("thought_id", "type", "target")
+	query.Where("thought_id = ?", thought.ID)
+	query.OrderBy("type", "ASC").OrderBy("target", "ASC")
+
+	if _, err := query.Load(&links.Outgoing); err != nil {
+		return nil, err
+	}
+
+	for _, link := range links.Outgoing {
+		switch link.Type {
+		case LinkTypeThought:
+			if target := store.thoughtByReference(ctx, link.Target); target != nil {
+				link.TargetID = target.ID
+				link.Title = target.Title()
+			}
+		case LinkTypeURL:
+			bookmark := Bookmark{URL: link.Target}
+			if err := store.BookmarkGet(ctx, &bookmark); err == nil {
+				link.TargetID = bookmark.ID
+				link.Title = bookmark.Title
+			}
+		}
+	}
+
+	backlinks, err := store.backlinks(ctx, "type = ? AND (target = ? OR target = ? COLLATE NOCASE)", LinkTypeThought, thought.ID, thought.Title())
+	if err != nil {
+		return nil, err
+	}
+
+	links.Backlinks = backlinks
+
+	return &links, nil
+}
+
+// BookmarkLinks returns the thoughts linking to the url of the bookmark
+func (store *Store) BookmarkLinks(ctx context.Context, bookmark *Bookmark) (*Links, error) {
+	backlinks, err := store.backlinks(ctx, "type = ? AND target = ?", LinkTypeURL, bookmark.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Links{Outgoing: []*Link{}, Backlinks: backlinks}, nil
+}
+
+// backlinks loads the links matching the condition together with the titles of the thoughts they originate from
+func (store *Store) backlinks(ctx context.Context, condition string, args ...interface{}) ([]*Link, error) {
+	backlinks := []*Link{}
+
+	query := store.db.Select(ctx).From("links")
+	query.Columns("thought_id", "type", "target")
+	query.Where(condition, args...)
+
+	if _, err := query.Load(&backlinks); err != nil {
+		return nil, err
+	}
+
+	for _, link := range backlinks {
+		source := Thought{ID: link.ThoughtID}
+		if err := store.ThoughtGet(ctx, &source); err == nil {
+			link.TargetID = source.ID
+			link.Title = source.Title()
+		}
+	}
+
+	return backlinks, nil
+}
+
+// thoughtByReference finds the thought with the given ID or title
+func (store *Store) thoughtByReference(ctx context.Context, reference string) *Thought {
+	thought := Thought{ID: reference}
+	if err := store.ThoughtGet(ctx, &thought); err == nil {
+		return &thought
+	}
+
+	candidates := []*Thought{}
+
+	query := store.db.Select(ctx).From("thoughts")
+	query.Columns("id", "created", "updated", "content", "tags")
+	query.Where("content LIKE ? ESCAPE '\\'", "%"+likeEscape(reference)+"%")
+	query.OrderBy("updated", "DESC")
+
+	if _, err := query.Load(&candidates); err != nil {
+		return nil
+	}
+
+	for _, candidate := range candidates {
+		if strings.EqualFold(candidate.Title(), reference) {
+			return candidate
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThoughtLinksParsing(t *testing.T) {
+	thought := Thought{ID: "1", Content: `See [[Other thought]] and [[2|the second one]], [[Other thought]] again.
+Also read https://example.com/article. and [a link](https://example.org/page?a=1).`}
+
+	links := thought.Links()
+
+	expected := []Link{
+		{ThoughtID: "1", Type: LinkTypeThought, Target: "Other thought"},
+		{ThoughtID: "1", Type: LinkTypeThought, Target: "2"},
+		{ThoughtID: "1", Type: LinkTypeURL, Target: "https://example.com/article"},
+		{ThoughtID: "1", Type: LinkTypeURL, Target: "https://example.org/page?a=1"},
+	}
+
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links but got %d", len(expected), len(links))
+	}
+
+	for i, link := range links {
+		if *link != expected[i] {
+			t.Fatalf("Expected %+v but got %+v", expected[i], link)
+		}
+	}
+}
+
+func TestThoughtLinks(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bookmark := Bookmark{URL: "https://example.com/article", Title: "An article"}
+	target := Thought{Content: "# Reading list\n\nhttps://example.com/article"}
+	source := Thought{Content: "Links to [[reading list]] and https://example.com/article"}
+
+	for _, err := range []error{store.BookmarkPersist(ctx, &bookmark), store.ThoughtPersist(ctx, &target), store.ThoughtPersist(ctx, &source)} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links, err := store.ThoughtLinks(ctx, &source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(links.Outgoing) != 2 || links.Outgoing[0].TargetID != target.ID || links.Outgoing[1].TargetID != bookmark.ID || links.Outgoing[1].Title != "An article" {
+		t.Fatalf("Unexpected outgoing links %+v", links.Outgoing)
+	}
+
+	links, err = store.ThoughtLinks(ctx, &target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(links.Backlinks) != 1 || links.Backlinks[0].TargetID != source.ID {
+		t.Fatalf("Unexpected backlinks %+v", links.Backlinks)
+	}
+
+	links, err = store.BookmarkLinks(ctx, &bookmark)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(links.Backlinks) != 2 {
+		t.Fatalf("Expected 2 backlinks to the bookmark but got %+v", links.Backlinks)
+	}
+
+	if err := store.ThoughtDelete(ctx, &source); err != nil {
+		t.Fatal(err)
+	}
+
+	if links, _ := store.BookmarkLinks(ctx, &bookmark); len(links.Backlinks) != 1 {
+		t.Fatalf("Expected the links of a deleted thought to be removed but got %+v", links.Backlinks)
+	}
+}
+
+func TestThoughtByReferenceWildcards(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thought := Thought{Content: "# 100% done_list"}
+	if err := store.ThoughtPersist(ctx, &thought); err != nil {
+		t.Fatal(err)
+	}
+
+	if found := store.thoughtByReference(ctx, "100% done_list"); found == nil || found.ID != thought.ID {
+		t.Fatalf("Expected the thought with wildcards in its title but got %v", found)
+	}
+
+	if escaped := likeEscape(`50%_a\b`); escaped != `50\%\_a\\b` {
+		t.Fatalf("Expected the wildcards to be escaped but got %s", escaped)
+	}
+
+	count := 0
+	store.db.Select(ctx).From("thoughts").Columns("COUNT(*)").Where("content LIKE ? ESCAPE '\\'", "%"+likeEscape("0_d")+"%").LoadValue(&count)
+	if count != 0 {
+		t.Fatalf("Expected _ to be matched literally but got %d thoughts", count)
+	}
+}
+
+func TestLinksBackfillRunsOnce(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thought := Thought{Content: "# Plain\n\nhttps://example.com/"}
+	if err := store.ThoughtPersist(ctx, &thought); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.db.Delete(ctx).From("links").Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	links := 0
+	store.db.Select(ctx).From("links").Columns("COUNT(*)").LoadValue(&links)
+	if links != 0 {
+		t.Fatalf("Expected the links to be indexed only by the migration but got %d links", links)
+	}
+}
+
+func TestLinksBackfillFailure(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	backfill := backfills["13_links.sql"]
+	defer func() {
+		backfills["13_links.sql"] = backfill
+	}()
+
+	backfills["13_links.sql"] = func(*Store, context.Context) error {
+		return errors.New("backfill failed")
+	}
+
+	if _, err := New(ctx, filepath.Join(tmpDir, "data.db")); err == nil {
+		t.Fatal("Expected the failing backfill to fail opening the store")
+	}
+
+	ran := false
+	backfills["13_links.sql"] = func(store *Store, ctx context.Context) error {
+		ran = true
+		return backfill(store, ctx)
+	}
+
+	// The migrations are rolled back together with the backfill, so they all run again
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := migrations.ReadDir("sql")
+
+	version := 0
+	store.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version)
+	if !ran || version != len(files) {
+		t.Fatalf("Expected the backfill to run again and user_version %d but got %v and %d", len(files), ran, version)
+	}
+}
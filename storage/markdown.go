@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	// markdown renders CommonMark with the GitHub Flavored Markdown extensions
+	markdown = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithASTTransformers(util.Prioritized(attachmentResolver{}, 100))),
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+
+	// markdownThoughtID holds the id of the thought being rendered in the parser context
+	markdownThoughtID = parser.NewContextKey()
+
+	// markdownPolicy sanitizes the rendered html, raw html in the markdown is
+	// allowed to pass the renderer and is cleaned up by this policy
+	markdownPolicy = newMarkdownPolicy()
+
+	// markdownInput matches the input elements in the sanitized html, which always has quoted attributes
+	markdownInput = regexp.MustCompile(`<input[^>]*>`)
+)
+
+func newMarkdownPolicy() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").OnElements("input")
+	policy.AllowAttrs("class").Matching(bluemonday.SpaceSeparatedTokens).OnElements("code")
+	policy.RequireNoFollowOnLinks(false)
+
+	return policy
+}
+
+// attachmentResolver rewrites links and images to attachment:<name> into
+// the url of the attachment of the thought being rendered
+type attachmentResolver struct{}
+
+func (attachmentResolver) Transform(document *ast.Document, reader text.Reader, pc parser.Context) {
+	thoughtID, _ := pc.Get(markdownThoughtID).(string)
+	if thoughtID == "" {
+		return
+	}
+
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node := node.(type) {
+		case *ast.Link:
+			node.Destination = resolveAttachment(thoughtID, node.Destination)
+		case *ast.Image:
+			node.Destination = resolveAttachment(thoughtID, node.Destination)
+		}
+
+		return ast.WalkContinue, nil
+	})
+}
+
+func resolveAttachment(thoughtID string, destination []byte) []byte {
+	if !bytes.HasPrefix(destination, []byte(attachmentScheme)) {
+		return destination
+	}
+
+	name, err := url.PathUnescape(strings.TrimPrefix(string(destination), attachmentScheme))
+	if err != nil {
+		return destination
+	}
+
+	return []byte(AttachmentURL(thoughtID, name))
+}
+
+// RenderMarkdown converts markdown to sanitized html
+func RenderMarkdown(source string) (string, error) {
+	return renderMarkdown(source, "")
+}
+
+// renderMarkdown converts markdown to sanitized html, references to
+// attachments are resolved if the id of the thought is given
+func renderMarkdown(source string, thoughtID string) (string, error) {
+	var buffer bytes.Buffer
+
+	pc := parser.NewContext()
+	pc.Set(markdownThoughtID, thoughtID)
+
+	if err := markdown.Convert([]byte(source), &buffer, parser.WithContext(pc)); err != nil {
+		return "", err
+	}
+
+	// The policy only removes attributes, so inputs that are not a checkbox of a task list are dropped here
+	sanitized := markdownInput.ReplaceAllStringFunc(markdownPolicy.Sanitize(buffer.String()), func(input string) string {
+		if strings.Contains(input, ` type="checkbox"`) {
+			return input
+		}
+		return ""
+	})
+
+	return sanitized, nil
+}
+
+// HTML returns the content of the thought rendered as html, with references
+// to its attachments resolved
+func (thought *Thought) HTML() (string, error) {
+	return renderMarkdown(thought.Content, thought.ID)
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	source := `# Title
+
+| a | b |
+|---|---|
+| 1 | 2 |
+
+- [x] done
+- [ ] todo
+
+Visit https://example.com and ~~not~~ this.
+
+<script>alert(1)</script>
+<a href="javascript:alert(1)" onclick="alert(1)">click</a>
+<input type="password" name="secret"> <input type="file"> <input checked> <input value="text">
+
+` + "```go\nfmt.Println()\n```\n"
+
+	rendered, err := RenderMarkdown(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"<h1>Title</h1>",
+		"<td>1</td>",
+		`<input checked="" disabled="" type="checkbox"> done`,
+		`<input disabled="" type="checkbox"> todo`,
+		`<a href="https://example.com">https://example.com</a>`,
+		"<del>not</del>",
+		`<code class="language-go">`,
+	} {
+		if !strings.Contains(rendered, expected) {
+			t.Fatalf("Expected %q in %s", expected, rendered)
+		}
+	}
+
+	for _, unexpected := range []string{"<script", "javascript:", "onclick", `type="password"`, `type="file"`, "<input>", `<input checked="">`, `value="text"`} {
+		if strings.Contains(rendered, unexpected) {
+			t.Fatalf("Did not expect %q in %s", unexpected, rendered)
+		}
+	}
+
+	if count := strings.Count(rendered, "<input"); count != 2 {
+		t.Fatalf("Expected only the 2 task list checkboxes but got %d inputs in %s", count, rendered)
+	}
+}
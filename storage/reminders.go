@@ -0,0 +1,347 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ReminderTypeBookmark is a reminder about a bookmark
+	ReminderTypeBookmark = "bookmark"
+
+	// ReminderTypeThought is a reminder about a thought
+	ReminderTypeThought = "thought"
+
+	// ReminderPending is the status of a reminder that has not been sent yet
+	ReminderPending = "pending"
+
+	// ReminderSent is the status of a reminder that was sent
+	ReminderSent = "sent"
+
+	// ReminderFailed is the status of a reminder that could not be sent after ReminderMaxAttempts
+	ReminderFailed = "failed"
+
+	// ReminderMaxAttempts is the number of times sending a reminder is tried
+	ReminderMaxAttempts = 3
+)
+
+var (
+	// ErrNoReminderKey is returned if the Reminder does not have an ID
+	ErrNoReminderKey = errors.New("Missing Reminder.ID")
+
+	// ErrReminderChanged is returned if the reminder was changed or rescheduled while it was being sent
+	ErrReminderChanged = errors.New("Reminder was changed while it was being sent")
+
+	// ErrInvalidReminder is returned if the Reminder does not have a valid Type, TargetID or Due date
+	ErrInvalidReminder = errors.New("Reminder needs a Type of bookmark or thought, an existing TargetID and a Due date")
+
+	relativeDuePattern = regexp.MustCompile(`^(?:in\s+)?(\d+)\s*(m|min|minutes?|h|hours?|d|days?|w|weeks?)$`)
+)
+
+// Reminder is a notification about a bookmark or thought that is sent when it is due
+type Reminder struct {
+	ID       string
+	Created  time.Time
+	Due      time.Time
+	Type     string
+	TargetID string
+	Note     string
+	Status   string
+	Attempts int
+	Error    string `json:",omitempty"`
+	Sent     time.Time
+
+	// Delivered are the names of the notifiers that sent the reminder, they
+	// are skipped when sending is retried
+	Delivered Tags
+
+	// Title and URL describe the bookmark or thought of the reminder
+	Title string `db:"-"`
+	URL   string `db:"-"`
+}
+
+// ReminderListOptions can be passed to ReminderList to filter reminders
+type ReminderListOptions struct {
+	Type     string
+	TargetID string
+	Status   string
+	Limit    int
+	Offset   int
+}
+
+// ParseReminderDue parses an absolute due date like 2026-10-20, 2026-10-20T15:04
+// or an RFC 3339 timestamp, or a due date relative to now like "3d", "in 2
+// hours" or "1w". Dates without a time are due at 09:00 local time.
+func ParseReminderDue(value string, now time.Time) (time.Time, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	if match := relativeDuePattern.FindStringSubmatch(value); match != nil {
+		amount, _ := strconv.Atoi(match[1])
+
+		switch match[2][0] {
+		case 'm':
+			return now.Add(time.Duration(amount) * time.Minute), nil
+		case 'h':
+			return now.Add(time.Duration(amount) * time.Hour), nil
+		case 'd':
+			return now.AddDate(0, 0, amount), nil
+		default:
+			return now.AddDate(0, 0, 7*amount), nil
+		}
+	}
+
+	if due, err := time.Parse(time.RFC3339, strings.ToUpper(value)); err == nil {
+		return due, nil
+	}
+
+	if due, err := time.ParseInLocation("2006-01-02t15:04", value, time.Local); err == nil {
+		return due, nil
+	}
+
+	if due, err := time.ParseInLocation(journalDateFormat, value, time.Local); err == nil {
+		return due.Add(9 * time.Hour), nil
+	}
+
+	return time.Time{}, ErrInvalidReminder
+}
+
+// ReminderList lists reminders, the first due first
+func (store *Store) ReminderList(ctx context.Context, options *ReminderListOptions) (*[]*Reminder, int) {
+	query := store.db.Select(ctx).From("reminders")
+
+	if options.Type != "" {
+		query.Where("type = ?", options.Type)
+	}
+
+	if options.TargetID != "" {
+		query.Where("target_id = ?", options.TargetID)
+	}
+
+	if options.Status != "" {
+		query.Where("status = ?", options.Status)
+	}
+
+	reminders := []*Reminder{}
+	totalCount := 0
+
+	query.Columns("COUNT(id)")
+	if err := query.LoadValue(&totalCount); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching reminder count")
+		return &reminders, 0
+	}
+
+	query.Columns("*")
+	query.OrderBy("due", "ASC")
+	query.Limit(options.Limit)
+	query.Offset(options.Offset)
+	if _, err := query.Load(&reminders); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching reminders")
+		return &reminders, 0
+	}
+
+	for _, reminder := range reminders {
+		store.reminderTarget(ctx, reminder)
+	}
+
+	return &reminders, totalCount
+}
+
+// ReminderGet gets a single reminder from the database
+func (store *Store) ReminderGet(ctx context.Context, reminder *Reminder) error {
+	if reminder.ID == "" {
+		return ErrNoReminderKey
+	}
+
+	query := store.db.Select(ctx).From("reminders")
+	query.Where("id = ?", reminder.ID)
+	query.Limit(1)
+
+	if err := query.LoadValue(&reminder); err != nil {
+		return err
+	}
+
+	store.reminderTarget(ctx, reminder)
+
+	return nil
+}
+
+// ReminderPersist adds a reminder about an existing bookmark or thought to
+// the database, or updates the due date and note of an existing reminder.
+// Changing the due date makes the reminder pending again.
+func (store *Store) ReminderPersist(ctx context.Context, reminder *Reminder) error {
+	if reminder.Due.IsZero() || (reminder.Type != ReminderTypeBookmark && reminder.Type != ReminderTypeThought) {
+		return ErrInvalidReminder
+	}
+
+	if !store.reminderTarget(ctx, reminder) {
+		return ErrInvalidReminder
+	}
+
+	// Due dates are compared as strings so they are always stored in utc
+	reminder.Due = reminder.Due.UTC().Truncate(time.Second)
+
+	previous := Reminder{}
+	if reminder.ID != "" {
+		store.db.Select(ctx).From("reminders").Columns("due").Where("id = ?", reminder.ID).LoadValue(&previous)
+	}
+
+	if !previous.Due.Equal(reminder.Due) {
+		reminder.Status = ReminderPending
+		reminder.Attempts = 0
+		reminder.Error = ""
+		reminder.Delivered = Tags{}
+	}
+
+	if reminder.Delivered == nil {
+		reminder.Delivered = Tags{}
+	}
+
+	if reminder.ID == "" {
+		reminder.ID = generateUUID()
+		reminder.Created = time.Now()
+
+		query := store.db.Insert(ctx).InTo("reminders")
+		query.Columns("id", "created", "due", "type", "target_id", "note", "status", "attempts", "error", "sent", "delivered")
+		query.Record(reminder)
+
+		if _, err := query.Exec(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("id", reminder.ID).Msg("Error persisting reminder")
+			return err
+		}
+	} else {
+		query := store.db.Update(ctx).Table("reminders")
+		query.Set("due", reminder.Due)
+		query.Set("note", reminder.Note)
+		query.Set("status", reminder.Status)
+		query.Set("attempts", reminder.Attempts)
+		query.Set("error", reminder.Error)
+		query.Set("delivered", reminder.Delivered)
+		query.Where("id = ?", reminder.ID)
+
+		if _, err := query.Exec(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("id", reminder.ID).Msg("Error updating reminder")
+			return err
+		}
+	}
+
+	log.Ctx(ctx).Info().Str("id", reminder.ID).Str("type", reminder.Type).Str("target", reminder.TargetID).Time("due", reminder.Due).Msg("Persisted reminder")
+
+	return nil
+}
+
+// ReminderDelete removes a reminder from the database
+func (store *Store) ReminderDelete(ctx context.Context, reminder *Reminder) error {
+	if reminder.ID == "" {
+		return ErrNoReminderKey
+	}
+
+	if _, err := store.db.Delete(ctx).From("reminders").Where("id = ?", reminder.ID).Exec(); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("id", reminder.ID).Msg("Error deleting reminder")
+		return err
+	}
+
+	log.Ctx(ctx).Info().Str("id", reminder.ID).Msg("Deleted reminder")
+
+	return nil
+}
+
+// ReminderDue returns the pending reminders that are due at the given time
+func (store *Store) ReminderDue(ctx context.Context, now time.Time) []*Reminder {
+	reminders := []*Reminder{}
+
+	query := store.db.Select(ctx).From("reminders")
+	query.Where("status = ? AND due <= ?", ReminderPending, now.UTC())
+	query.OrderBy("due", "ASC")
+
+	if _, err := query.Load(&reminders); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching due reminders")
+		return reminders
+	}
+
+	for _, reminder := range reminders {
+		store.reminderTarget(ctx, reminder)
+	}
+
+	return reminders
+}
+
+// ReminderDelivered records the result of sending the reminder including its
+// Delivered notifiers, a failed reminder stays pending until it failed
+// ReminderMaxAttempts times
+func (store *Store) ReminderDelivered(ctx context.Context, reminder *Reminder, err error) error {
+	reminder.Attempts++
+
+	if reminder.Delivered == nil {
+		reminder.Delivered = Tags{}
+	}
+
+	if err == nil {
+		reminder.Status = ReminderSent
+		reminder.Error = ""
+		reminder.Sent = time.Now()
+	} else {
+		reminder.Error = err.Error()
+		if reminder.Attempts >= ReminderMaxAttempts {
+			reminder.Status = ReminderFailed
+		}
+	}
+
+	query := store.db.Update(ctx).Table("reminders")
+	query.Set("status", reminder.Status)
+	query.Set("attempts", reminder.Attempts)
+	query.Set("error", reminder.Error)
+	query.Set("sent", reminder.Sent)
+	query.Set("delivered", reminder.Delivered)
+
+	// A reminder that was rescheduled or handled while it was being sent keeps its new state
+	query.Where("id = ? AND due = ? AND status = ?", reminder.ID, reminder.Due, ReminderPending)
+
+	result, err := query.Exec()
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("id", reminder.ID).Msg("Error updating reminder")
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrReminderChanged
+	}
+
+	return nil
+}
+
+// reminderTarget fills the title and url of the bookmark or thought of the
+// reminder and returns false if it does not exist
+func (store *Store) reminderTarget(ctx context.Context, reminder *Reminder) bool {
+	switch reminder.Type {
+	case ReminderTypeBookmark:
+		bookmark := Bookmark{ID: reminder.TargetID}
+		if reminder.TargetID == "" || store.BookmarkGet(ctx, &bookmark) != nil {
+			return false
+		}
+
+		reminder.Title = bookmark.Title
+		reminder.URL = bookmark.URL
+	case ReminderTypeThought:
+		thought := Thought{ID: reminder.TargetID}
+		if reminder.TargetID == "" || store.ThoughtGet(ctx, &thought) != nil {
+			return false
+		}
+
+		reminder.Title = thought.Title()
+		if store.CallbackURL != "" {
+			reminder.URL = strings.TrimSuffix(store.CallbackURL, "/") + "/#/thoughts/" + thought.ID
+		}
+	default:
+		return false
+	}
+
+	return true
+}
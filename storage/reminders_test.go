@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseReminderDue(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)
+
+	tests := map[string]time.Time{
+		"3d":                   now.AddDate(0, 0, 3),
+		"in 3 days":            now.AddDate(0, 0, 3),
+		"2h":                   now.Add(2 * time.Hour),
+		"in 30 minutes":        now.Add(30 * time.Minute),
+		"1w":                   now.AddDate(0, 0, 7),
+		"2026-10-20":           time.Date(2026, 10, 20, 9, 0, 0, 0, time.Local),
+		"2026-10-20T15:04":     time.Date(2026, 10, 20, 15, 4, 0, 0, time.Local),
+		"2026-10-20T15:04:05Z": time.Date(2026, 10, 20, 15, 4, 5, 0, time.UTC),
+	}
+
+	for value, expected := range tests {
+		if due, err := ParseReminderDue(value, now); err != nil || !due.Equal(expected) {
+			t.Fatalf("Expected %s for %q but got %s: %v", expected, value, due, err)
+		}
+	}
+
+	if _, err := ParseReminderDue("someday", now); err != ErrInvalidReminder {
+		t.Fatalf("Expected ErrInvalidReminder but got %v", err)
+	}
+}
+
+func TestReminders(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.CallbackURL = "https://example.com/"
+
+	thought := Thought{Content: "# Call the bank"}
+	if err := store.ThoughtPersist(ctx, &thought); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.ReminderPersist(ctx, &Reminder{Type: ReminderTypeThought, TargetID: "missing", Due: time.Now()}); err != ErrInvalidReminder {
+		t.Fatalf("Expected ErrInvalidReminder but got %v", err)
+	}
+
+	now := time.Now()
+	soon := Reminder{Type: ReminderTypeThought, TargetID: thought.ID, Due: now.Add(-time.Minute), Note: "before friday"}
+	later := Reminder{Type: ReminderTypeThought, TargetID: thought.ID, Due: now.Add(time.Hour)}
+	for _, reminder := range []*Reminder{&later, &soon} {
+		if err := store.ReminderPersist(ctx, reminder); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if soon.Title != "Call the bank" || soon.URL != "https://example.com/#/thoughts/"+thought.ID {
+		t.Fatalf("Unexpected title %q and url %q", soon.Title, soon.URL)
+	}
+
+	due := store.ReminderDue(ctx, now)
+	if len(due) != 1 || due[0].ID != soon.ID {
+		t.Fatalf("Expected only the first reminder to be due but got %+v", due)
+	}
+
+	due[0].Delivered = Tags{"webhook"}
+	for attempt := 1; attempt <= ReminderMaxAttempts; attempt++ {
+		if err := store.ReminderDelivered(ctx, due[0], errors.New("unreachable")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if failed := (Reminder{ID: soon.ID}); store.ReminderGet(ctx, &failed) != nil || len(failed.Delivered) != 1 || failed.Delivered[0] != "webhook" {
+		t.Fatalf("Expected the delivered notifiers to be stored but got %v", failed.Delivered)
+	}
+
+	if due[0].Status != ReminderFailed || len(store.ReminderDue(ctx, now)) != 0 {
+		t.Fatalf("Expected the reminder to fail after %d attempts but got %+v", ReminderMaxAttempts, due[0])
+	}
+
+	// A reminder that is rescheduled while it is being sent stays pending
+	stale := later
+	rescheduled := later
+	rescheduled.Due = later.Due.Add(time.Minute)
+	if err := store.ReminderPersist(ctx, &rescheduled); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.ReminderDelivered(ctx, &stale, nil); err != ErrReminderChanged {
+		t.Fatalf("Expected ErrReminderChanged but got %v", err)
+	}
+
+	if reminder := (Reminder{ID: later.ID}); store.ReminderGet(ctx, &reminder) != nil || reminder.Status != ReminderPending {
+		t.Fatalf("Expected the rescheduled reminder to stay pending but got %s", reminder.Status)
+	}
+
+	if err := store.ReminderDelivered(ctx, &rescheduled, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	reminder := Reminder{ID: later.ID}
+	if err := store.ReminderGet(ctx, &reminder); err != nil || reminder.Status != ReminderSent || reminder.Sent.IsZero() {
+		t.Fatalf("Expected the reminder to be sent but got %+v: %v", reminder, err)
+	}
+
+	// Changing only the note keeps a sent reminder sent
+	reminder.Note = "changed"
+	if err := store.ReminderPersist(ctx, &reminder); err != nil || reminder.Status != ReminderSent {
+		t.Fatalf("Expected the reminder to stay sent but got %s: %v", reminder.Status, err)
+	}
+
+	reminder.Due = reminder.Due.Add(time.Hour)
+	if err := store.ReminderPersist(ctx, &reminder); err != nil || reminder.Status != ReminderPending || reminder.Attempts != 0 {
+		t.Fatalf("Expected a new due date to make the reminder pending but got %s: %v", reminder.Status, err)
+	}
+
+	if _, total := store.ReminderList(ctx, &ReminderListOptions{Status: ReminderFailed}); total != 1 {
+		t.Fatalf("Expected 1 failed reminder but got %d", total)
+	}
+
+	if err := store.ThoughtDelete(ctx, &thought); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, total := store.ReminderList(ctx, &ReminderListOptions{}); total != 0 {
+		t.Fatalf("Expected the reminders of the deleted thought to be removed but got %d", total)
+	}
+}
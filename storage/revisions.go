@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrNoRevisionKey is returned if the ThoughtRevision does not have a ID or ThoughtID
+	ErrNoRevisionKey = errors.New("Missing ThoughtRevision.ID or ThoughtRevision.ThoughtID")
+)
+
+// ThoughtRevision is a version of the content and tags of a thought
+type ThoughtRevision struct {
+	ID        int64
+	ThoughtID string
+	Created   time.Time
+	Content   string `json:",omitempty"`
+	Tags      Tags
+}
+
+// ThoughtRevisionList lists the revisions of a thought, newest first, without their content
+func (store *Store) ThoughtRevisionList(ctx context.Context, thought *Thought) (*[]*ThoughtRevision, error) {
+	revisions := []*ThoughtRevision{}
+
+	query := store.db.Select(ctx).From("thought_revisions")
+	query.Columns("id", "thought_id", "created", "tags")
+	query.Where("thought_id = ?", thought.ID)
+	query.OrderBy("id", "DESC")
+
+	if _, err := query.Load(&revisions); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("id", thought.ID).Msg("Error fetching thought revisions")
+		return &revisions, err
+	}
+
+	return &revisions, nil
+}
+
+// ThoughtRevisionGet gets a single revision of a thought
+func (store *Store) ThoughtRevisionGet(ctx context.Context, revision *ThoughtRevision) error {
+	if revision.ID == 0 || revision.ThoughtID == "" {
+		return ErrNoRevisionKey
+	}
+
+	query := store.db.Select(ctx).From("thought_revisions")
+	query.Where("id = ? AND thought_id = ?", revision.ID, revision.ThoughtID)
+	query.Limit(1)
+
+	return query.LoadValue(&revision)
+}
+
+// ThoughtRestore restores the content and tags of the thought to those of the revision
+func (store *Store) ThoughtRestore(ctx context.Context, thought *Thought, revision *ThoughtRevision) error {
+	thought.Content = revision.Content
+	thought.Tags = revision.Tags
+
+	if err := store.ThoughtPersist(ctx, thought); err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Str("id", thought.ID).Int64("revision", revision.ID).Msg("Restored thought")
+
+	return nil
+}
+
+// revisionPersist stores the current content and tags of the thought as a new
+// revision and removes the oldest revisions exceeding Store.RevisionLimit
+func (store *Store) revisionPersist(ctx context.Context, thought *Thought) error {
+	latest := ThoughtRevision{}
+
+	query := store.db.Select(ctx).From("thought_revisions")
+	query.Where("thought_id = ?", thought.ID)
+	query.OrderBy("id", "DESC")
+	query.Limit(1)
+
+	if err := query.LoadValue(&latest); err == nil && latest.Content == thought.Content && equalTags(latest.Tags, thought.Tags) {
+		return nil
+	}
+
+	revision := ThoughtRevision{
+		ThoughtID: thought.ID,
+		Created:   thought.Updated,
+		Content:   thought.Content,
+		Tags:      thought.Tags,
+	}
+
+	insert := store.db.Insert(ctx).InTo("thought_revisions")
+	insert.Columns("thought_id", "created", "content", "tags")
+	insert.Record(&revision)
+
+	if _, err := insert.Exec(); err != nil {
+		return err
+	}
+
+	if store.RevisionLimit <= 0 {
+		return nil
+	}
+
+	cleanup := store.db.Delete(ctx).From("thought_revisions")
+	cleanup.Where("thought_id = ? AND id NOT IN (SELECT id FROM thought_revisions WHERE thought_id = ? ORDER BY id DESC LIMIT ?)", thought.ID, thought.ID, store.RevisionLimit)
+
+	_, err := cleanup.Exec()
+
+	return err
+}
+
+func equalTags(a, b Tags) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThoughtRevisions(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.RevisionLimit = 3
+
+	thought := Thought{Content: "first"}
+	for _, content := range []string{"first", "second", "second", "third", "fourth"} {
+		thought.Content = content
+		if err := store.ThoughtPersist(ctx, &thought); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	revisions, err := store.ThoughtRevisionList(ctx, &thought)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*revisions) != 3 {
+		t.Fatalf("Expected 3 revisions but got %d", len(*revisions))
+	}
+
+	oldest := (*revisions)[2]
+	if err := store.ThoughtRevisionGet(ctx, oldest); err != nil || oldest.Content != "second" {
+		t.Fatalf("Expected the oldest revision to be second but got %q: %v", oldest.Content, err)
+	}
+
+	if err := store.ThoughtRestore(ctx, &thought, oldest); err != nil {
+		t.Fatal(err)
+	}
+
+	current := Thought{ID: thought.ID}
+	if err := store.ThoughtGet(ctx, &current); err != nil || current.Content != "second" {
+		t.Fatalf("Expected the thought to be restored but got %q: %v", current.Content, err)
+	}
+
+	if err := store.ThoughtRevisionGet(ctx, &ThoughtRevision{ID: oldest.ID, ThoughtID: "other"}); err == nil {
+		t.Fatal("Expected revisions of other thoughts not to be found")
+	}
+
+	if err := store.ThoughtDelete(ctx, &thought); err != nil {
+		t.Fatal(err)
+	}
+
+	if revisions, _ := store.ThoughtRevisionList(ctx, &thought); len(*revisions) != 0 {
+		t.Fatalf("Expected the revisions to be removed with the thought but got %d", len(*revisions))
+	}
+}
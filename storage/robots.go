@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robots holds the rules of a robots.txt group
+type robots struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow bool
+	path  string
+}
+
+// parseRobots parses a robots.txt file and returns the group of rules for the
+// user agent, or the * group if there is no group for the user agent
+func parseRobots(body io.Reader, userAgent string) *robots {
+	groups := []*robots{}
+
+	var group *robots
+	scanner := bufio.NewScanner(body)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+
+		if key == "user-agent" {
+			// Consecutive user-agent lines share the same group
+			if group == nil || len(group.rules) > 0 || group.crawlDelay > 0 {
+				group = &robots{}
+				groups = append(groups, group)
+			}
+			group.agents = append(group.agents, strings.ToLower(value))
+			continue
+		}
+
+		if group == nil {
+			continue
+		}
+
+		switch key {
+		case "allow", "disallow":
+			if value != "" {
+				group.rules = append(group.rules, robotsRule{key == "allow", value})
+			}
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				group.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	userAgent = strings.ToLower(userAgent)
+	wildcard := &robots{}
+
+	for _, group := range groups {
+		for _, agent := range group.agents {
+			if agent == "*" {
+				wildcard = group
+			} else if agent != "" && strings.Contains(userAgent, agent) {
+				return group
+			}
+		}
+	}
+
+	return wildcard
+}
+
+// allowed returns true if the path may be fetched, the longest matching rule
+// wins and allow rules win from disallow rules of the same length
+func (r *robots) allowed(path string) bool {
+	allowed, length := true, -1
+
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.path, path) {
+			continue
+		}
+
+		if len(rule.path) > length || (len(rule.path) == length && rule.allow) {
+			allowed, length = rule.allow, len(rule.path)
+		}
+	}
+
+	return allowed
+}
+
+// robotsMatch matches a path against a robots.txt pattern supporting * wildcards and a $ end anchor
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+
+	position := len(parts[0])
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(path, part) && len(path)-len(part) >= position
+		}
+
+		index := strings.Index(path[position:], part)
+		if index < 0 {
+			return false
+		}
+		position += index + len(part)
+	}
+
+	return !anchored || position == len(path)
+}
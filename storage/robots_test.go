@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	body := `# robots.txt
+User-agent: GoogleBot
+User-agent: bookmarks
+Disallow: /
+Allow: /public/
+Allow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: *
+Disallow: /private # comment
+`
+
+	robots := parseRobots(strings.NewReader(body), "Mozilla/5.0 (compatible; Bookmarks/1.0)")
+
+	paths := map[string]bool{
+		"/":                            false,
+		"/article":                     false,
+		"/public/article":              true,
+		"/papers/paper.pdf":            true,
+		"/papers/paper.pdf?download=1": false,
+	}
+
+	for path, expected := range paths {
+		if allowed := robots.allowed(path); allowed != expected {
+			t.Fatalf("Expected %s to be allowed=%v", path, expected)
+		}
+	}
+
+	if robots.crawlDelay != 2*time.Second {
+		t.Fatalf("Expected a crawl delay of 2s but got %s", robots.crawlDelay)
+	}
+
+	robots = parseRobots(strings.NewReader(body), "curl/7.0")
+
+	if robots.allowed("/private/page") || !robots.allowed("/article") {
+		t.Fatalf("Expected the wildcard group to be used but got %+v", robots)
+	}
+
+	if robots = parseRobots(strings.NewReader(""), "curl/7.0"); !robots.allowed("/private") {
+		t.Fatal("Expected everything to be allowed by an empty robots.txt")
+	}
+}
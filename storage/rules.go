@@ -0,0 +1,314 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// RuleFieldTitle matches a rule against the title of a feed item
+	RuleFieldTitle = "title"
+
+	// RuleFieldURL matches a rule against the url of a feed item
+	RuleFieldURL = "url"
+
+	// RuleFieldContent matches a rule against the content of a feed item
+	RuleFieldContent = "content"
+
+	// RuleFieldTags matches a rule against the tags of the feed
+	RuleFieldTags = "tags"
+
+	// RuleOperatorContains matches if the field contains the pattern, case insensitive
+	RuleOperatorContains = "contains"
+
+	// RuleOperatorRegex matches if the field matches the pattern as a regular expression
+	RuleOperatorRegex = "regex"
+
+	// RuleActionDiscard removes matching items from the feed
+	RuleActionDiscard = "discard"
+
+	// RuleActionRead marks matching items as read
+	RuleActionRead = "read"
+
+	// RuleActionTag adds Rule.Tag to matching items
+	RuleActionTag = "tag"
+
+	// RuleActionBookmark saves matching items as a bookmark
+	RuleActionBookmark = "bookmark"
+)
+
+var (
+	// ErrNoRuleKey is returned if the Rule does not have an ID
+	ErrNoRuleKey = errors.New("Missing Rule.ID")
+
+	// ErrInvalidRuleField is returned if the Rule has an unknown Field
+	ErrInvalidRuleField = errors.New("Invalid Rule.Field")
+
+	// ErrInvalidRuleOperator is returned if the Rule has an unknown Operator
+	ErrInvalidRuleOperator = errors.New("Invalid Rule.Operator")
+
+	// ErrInvalidRuleAction is returned if the Rule has an unknown Action
+	ErrInvalidRuleAction = errors.New("Invalid Rule.Action")
+
+	// ErrNoRulePattern is returned if the Rule does not have a Pattern
+	ErrNoRulePattern = errors.New("Missing Rule.Pattern")
+
+	// ErrNoRuleTag is returned if a Rule with the tag action does not have a Tag
+	ErrNoRuleTag = errors.New("Missing Rule.Tag")
+)
+
+// Rule represents a filter rule that is evaluated against new feed items
+type Rule struct {
+	ID       string
+	Created  time.Time
+	Updated  time.Time
+	Name     string
+	Field    string
+	Operator string
+	Pattern  string
+	Action   string
+	Tag      string
+
+	re *regexp.Regexp
+}
+
+// Validate checks if the rule is complete and its pattern compiles
+func (rule *Rule) Validate() error {
+	switch rule.Field {
+	case RuleFieldTitle, RuleFieldURL, RuleFieldContent, RuleFieldTags:
+	default:
+		return ErrInvalidRuleField
+	}
+
+	switch rule.Action {
+	case RuleActionDiscard, RuleActionRead, RuleActionBookmark:
+	case RuleActionTag:
+		if rule.Tag == "" {
+			return ErrNoRuleTag
+		}
+	default:
+		return ErrInvalidRuleAction
+	}
+
+	if rule.Pattern == "" {
+		return ErrNoRulePattern
+	}
+
+	switch rule.Operator {
+	case RuleOperatorContains:
+	case RuleOperatorRegex:
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return err
+		}
+		rule.re = re
+	default:
+		return ErrInvalidRuleOperator
+	}
+
+	return nil
+}
+
+// Matches returns true if the given item of the feed matches this rule
+func (rule *Rule) Matches(feed *Feed, item *FeedItem) bool {
+	var values []string
+
+	switch rule.Field {
+	case RuleFieldTitle:
+		values = []string{item.Title}
+	case RuleFieldURL:
+		values = []string{item.URL}
+	case RuleFieldContent:
+		values = []string{item.Content}
+	case RuleFieldTags:
+		values = feed.Tags
+	}
+
+	for _, value := range values {
+		if rule.matchValue(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (rule *Rule) matchValue(value string) bool {
+	if rule.Operator == RuleOperatorContains {
+		return strings.Contains(strings.ToLower(value), strings.ToLower(rule.Pattern))
+	}
+
+	if rule.re == nil {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return false
+		}
+		rule.re = re
+	}
+
+	return rule.re.MatchString(value)
+}
+
+// Apply performs the action of this rule on the given item, it returns false if the item must be discarded
+func (rule *Rule) Apply(item *FeedItem) bool {
+	switch rule.Action {
+	case RuleActionDiscard:
+		return false
+	case RuleActionRead:
+		item.Read = true
+	case RuleActionTag:
+		for _, tag := range item.Tags {
+			if tag == rule.Tag {
+				return true
+			}
+		}
+		item.Tags = append(item.Tags, rule.Tag)
+	}
+
+	return true
+}
+
+// RuleMatch is a feed item that matches a Rule
+type RuleMatch struct {
+	FeedID    string
+	FeedTitle string
+	Item      *FeedItem
+}
+
+// RuleListOptions can be passed to RuleList to filter rules
+type RuleListOptions struct {
+	Limit  int
+	Offset int
+}
+
+// RuleList fetches multiple rules from the database
+func (store *Store) RuleList(ctx context.Context, options *RuleListOptions) (*[]*Rule, int) {
+	query := store.db.Select(ctx).From("rules")
+
+	rules := []*Rule{}
+	totalCount := 0
+
+	query.Columns("COUNT(id)")
+	if err := query.LoadValue(&totalCount); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching rules count")
+		return &rules, 0
+	}
+
+	query.Columns("*")
+	query.OrderBy("created", "ASC")
+	query.Limit(options.Limit)
+	query.Offset(options.Offset)
+	if _, err := query.Load(&rules); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching rules")
+		return &rules, 0
+	}
+
+	return &rules, totalCount
+}
+
+// RuleGet finds a single rule by ID
+func (store *Store) RuleGet(ctx context.Context, rule *Rule) error {
+	if rule.ID == "" {
+		return ErrNoRuleKey
+	}
+
+	query := store.db.Select(ctx).From("rules")
+	query.Where("id = ?", rule.ID)
+	query.Limit(1)
+
+	if err := query.LoadValue(&rule); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RulePersist validates and persists a rule to the database
+func (store *Store) RulePersist(ctx context.Context, rule *Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	if rule.Created.IsZero() {
+		rule.Created = time.Now()
+	}
+
+	rule.Updated = time.Now()
+
+	if rule.ID == "" {
+		rule.ID = generateUUID()
+
+		query := store.db.Insert(ctx).InTo("rules")
+		query.Columns("id", "created", "updated", "name", "field", "operator", "pattern", "action", "tag")
+		query.Record(rule)
+
+		if _, err := query.Exec(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("id", rule.ID).Msg("Error creating rule")
+			return err
+		}
+	} else {
+		query := store.db.Update(ctx).Table("rules")
+		query.Set("updated", rule.Updated)
+		query.Set("name", rule.Name)
+		query.Set("field", rule.Field)
+		query.Set("operator", rule.Operator)
+		query.Set("pattern", rule.Pattern)
+		query.Set("action", rule.Action)
+		query.Set("tag", rule.Tag)
+		query.Where("id = ?", rule.ID)
+
+		if _, err := query.Exec(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("id", rule.ID).Msg("Error updating rule")
+			return err
+		}
+	}
+
+	log.Ctx(ctx).Info().Str("id", rule.ID).Msg("Persisted rule")
+
+	return nil
+}
+
+// RuleDelete deletes the given rule from the database
+func (store *Store) RuleDelete(ctx context.Context, rule *Rule) error {
+	if rule.ID == "" {
+		return ErrNoRuleKey
+	}
+
+	query := store.db.Delete(ctx).From("rules")
+	query.Where("id = ?", rule.ID)
+
+	if _, err := query.Exec(); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("id", rule.ID).Msg("Error deleting rule")
+		return err
+	}
+
+	log.Ctx(ctx).Info().Str("id", rule.ID).Msg("Rule deleted")
+
+	return nil
+}
+
+// RulePreview evaluates the given rule against all existing feed items without performing its action
+func (store *Store) RulePreview(ctx context.Context, rule *Rule) (*[]*RuleMatch, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	matches := []*RuleMatch{}
+
+	feeds, _ := store.FeedList(ctx, &FeedListOptions{Limit: -1})
+
+	for _, feed := range *feeds {
+		for _, item := range feed.Items {
+			if rule.Matches(feed, item) {
+				matches = append(matches, &RuleMatch{FeedID: feed.ID, FeedTitle: feed.Title, Item: item})
+			}
+		}
+	}
+
+	return &matches, nil
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestRuleValidate(t *testing.T) {
+	rules := map[error]*Rule{
+		ErrInvalidRuleField:    {Field: "author", Operator: RuleOperatorContains, Pattern: "go", Action: RuleActionRead},
+		ErrInvalidRuleOperator: {Field: RuleFieldTitle, Operator: "equals", Pattern: "go", Action: RuleActionRead},
+		ErrInvalidRuleAction:   {Field: RuleFieldTitle, Operator: RuleOperatorContains, Pattern: "go", Action: "star"},
+		ErrNoRulePattern:       {Field: RuleFieldTitle, Operator: RuleOperatorContains, Action: RuleActionRead},
+		ErrNoRuleTag:           {Field: RuleFieldTitle, Operator: RuleOperatorContains, Pattern: "go", Action: RuleActionTag},
+	}
+
+	for expected, rule := range rules {
+		if err := rule.Validate(); err != expected {
+			t.Fatalf("Expected %v but got %v", expected, err)
+		}
+	}
+
+	rule := &Rule{Field: RuleFieldTitle, Operator: RuleOperatorRegex, Pattern: "(", Action: RuleActionRead}
+	if err := rule.Validate(); err == nil {
+		t.Fatal("Expected an invalid regex to fail validation")
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	feed := &Feed{Tags: Tags{"news", "golang"}}
+	item := &FeedItem{Title: "Go 1.17 is released", URL: "https://blog.golang.org/go1.17", Content: "Sponsored content"}
+
+	tests := []struct {
+		rule    *Rule
+		matches bool
+	}{
+		{&Rule{Field: RuleFieldTitle, Operator: RuleOperatorContains, Pattern: "released"}, true},
+		{&Rule{Field: RuleFieldTitle, Operator: RuleOperatorContains, Pattern: "RELEASED"}, true},
+		{&Rule{Field: RuleFieldTitle, Operator: RuleOperatorRegex, Pattern: `^Go 1\.\d+`}, true},
+		{&Rule{Field: RuleFieldURL, Operator: RuleOperatorContains, Pattern: "example.com"}, false},
+		{&Rule{Field: RuleFieldContent, Operator: RuleOperatorRegex, Pattern: `(?i)sponsored`}, true},
+		{&Rule{Field: RuleFieldTags, Operator: RuleOperatorRegex, Pattern: `^golang$`}, true},
+		{&Rule{Field: RuleFieldTags, Operator: RuleOperatorContains, Pattern: "rust"}, false},
+	}
+
+	for _, test := range tests {
+		if test.rule.Matches(feed, item) != test.matches {
+			t.Fatalf("Expected rule %s %s %q to return %v", test.rule.Field, test.rule.Operator, test.rule.Pattern, test.matches)
+		}
+	}
+}
+
+func TestRuleApply(t *testing.T) {
+	item := &FeedItem{}
+
+	if (&Rule{Action: RuleActionDiscard}).Apply(item) {
+		t.Fatal("Expected the discard action to drop the item")
+	}
+
+	(&Rule{Action: RuleActionRead}).Apply(item)
+	if !item.Read {
+		t.Fatal("Expected the read action to mark the item as read")
+	}
+
+	(&Rule{Action: RuleActionTag, Tag: "golang"}).Apply(item)
+	(&Rule{Action: RuleActionTag, Tag: "golang"}).Apply(item)
+	if len(item.Tags) != 1 || item.Tags[0] != "golang" {
+		t.Fatalf("Expected the tag action to add a single tag, got %v", item.Tags)
+	}
+}
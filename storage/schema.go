@@ -3,11 +3,24 @@ package storage
 import (
 	"context"
 	"embed"
+	"fmt"
+
+	"github.com/nrocco/qb"
 )
 
 //go:embed sql/*.sql
 var migrations embed.FS
 
+// backfills fill the tables created by a migration with data that can only be
+// derived in go, they run in the same transaction as the migrations so a
+// failing backfill is retried together with its migration
+var backfills = map[string]func(*Store, context.Context) error{
+	"13_links.sql": (*Store).linksReindex,
+	"17_tasks.sql": (*Store).tasksReindex,
+}
+
+// migrate runs all migrations that have not been applied yet. The number of
+// applied migrations is tracked in the user_version pragma of the database.
 func (store *Store) migrate(ctx context.Context) error {
 	tx, err := store.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -20,7 +33,22 @@ func (store *Store) migrate(ctx context.Context) error {
 		return err
 	}
 
-	for _, file := range files {
+	version := 0
+	if err := tx.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	pending := []func(*Store, context.Context) error{}
+
+	for i, file := range files {
+		if i < version {
+			continue
+		}
+
+		if backfill, ok := backfills[file.Name()]; ok {
+			pending = append(pending, backfill)
+		}
+
 		migration, err := migrations.ReadFile("sql/" + file.Name())
 		if err != nil {
 			return err
@@ -30,10 +58,18 @@ func (store *Store) migrate(ctx context.Context) error {
 		}
 	}
 
-	err = tx.Commit()
-	if err != nil {
+	// The queries of the backfills use the transaction that is added to the context
+	txCtx := qb.WitTx(ctx, tx)
+
+	for _, backfill := range pending {
+		if err := backfill(store, txCtx); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", len(files))); err != nil {
 		return err
 	}
 
-	return err
+	return tx.Commit()
 }
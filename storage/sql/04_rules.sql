@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS rules (
+    id CHAR(16) PRIMARY KEY,
+    created DATE DEFAULT (datetime('now')),
+    updated DATE DEFAULT (datetime('now')),
+    name VARCHAR(64) NOT NULL DEFAULT '',
+    field VARCHAR(16) NOT NULL,
+    operator VARCHAR(16) NOT NULL,
+    pattern VARCHAR(255) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    tag VARCHAR(64) NOT NULL DEFAULT ''
+);
@@ -0,0 +1 @@
+ALTER TABLE feeds ADD COLUMN fetch_content BOOLEAN NOT NULL DEFAULT 0;
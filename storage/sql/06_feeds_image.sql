@@ -0,0 +1 @@
+ALTER TABLE feeds ADD COLUMN image VARCHAR(255) NOT NULL DEFAULT '';
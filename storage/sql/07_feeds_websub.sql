@@ -0,0 +1,4 @@
+ALTER TABLE feeds ADD COLUMN hub VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN hub_topic VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN hub_secret VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN hub_expires DATE NOT NULL DEFAULT '0001-01-01 00:00:00 +0000 UTC';
@@ -0,0 +1,3 @@
+ALTER TABLE feeds ADD COLUMN active BOOLEAN NOT NULL DEFAULT 1;
+ALTER TABLE feeds ADD COLUMN last_modified VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN retry_after DATE NOT NULL DEFAULT '0001-01-01 00:00:00 +0000 UTC';
@@ -0,0 +1 @@
+ALTER TABLE feeds ADD COLUMN credentials TEXT NOT NULL DEFAULT '';
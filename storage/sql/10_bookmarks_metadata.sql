@@ -0,0 +1,7 @@
+ALTER TABLE bookmarks ADD COLUMN author VARCHAR(128) NOT NULL DEFAULT '';
+ALTER TABLE bookmarks ADD COLUMN site_name VARCHAR(128) NOT NULL DEFAULT '';
+ALTER TABLE bookmarks ADD COLUMN published DATE NOT NULL DEFAULT '0001-01-01 00:00:00 +0000 UTC';
+ALTER TABLE bookmarks ADD COLUMN image VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE bookmarks ADD COLUMN favicon VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE bookmarks ADD COLUMN language VARCHAR(8) NOT NULL DEFAULT '';
+ALTER TABLE bookmarks ADD COLUMN reading_time INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS images (
+    id VARCHAR(64) PRIMARY KEY,
+    url TEXT NOT NULL,
+    created DATE NOT NULL,
+    accessed DATE NOT NULL,
+    content_type VARCHAR(64) NOT NULL DEFAULT '',
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    size INTEGER NOT NULL DEFAULT 0,
+    data BLOB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS images_accessed ON images (accessed);
@@ -0,0 +1,2 @@
+ALTER TABLE bookmarks ADD COLUMN content_type VARCHAR(128) NOT NULL DEFAULT '';
+ALTER TABLE bookmarks ADD COLUMN pages INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS links (
+    thought_id CHAR(16) NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    target TEXT NOT NULL,
+    PRIMARY KEY (thought_id, type, target)
+);
+
+CREATE INDEX IF NOT EXISTS links_target ON links (target COLLATE NOCASE);
+
+CREATE TRIGGER IF NOT EXISTS links_thoughts_ad AFTER DELETE ON thoughts BEGIN
+    DELETE FROM links WHERE thought_id = old.id;
+END;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS thought_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    thought_id CHAR(16) NOT NULL,
+    created DATE NOT NULL,
+    tags JSON NOT NULL DEFAULT '[]',
+    content TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS thought_revisions_thought_id ON thought_revisions (thought_id, id);
+
+CREATE TRIGGER IF NOT EXISTS thought_revisions_thoughts_ad AFTER DELETE ON thoughts BEGIN
+    DELETE FROM thought_revisions WHERE thought_id = old.id;
+END;
+
+INSERT INTO thought_revisions (thought_id, created, tags, content) SELECT id, updated, tags, content FROM thoughts;
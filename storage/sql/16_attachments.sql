@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS blobs (
+    hash VARCHAR(64) PRIMARY KEY,
+    size INTEGER NOT NULL DEFAULT 0,
+    data BLOB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS attachments (
+    thought_id CHAR(16) NOT NULL,
+    name TEXT NOT NULL,
+    hash VARCHAR(64) NOT NULL,
+    content_type VARCHAR(64) NOT NULL DEFAULT '',
+    size INTEGER NOT NULL DEFAULT 0,
+    created DATE NOT NULL,
+    PRIMARY KEY (thought_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS attachments_hash ON attachments (hash);
+
+CREATE TRIGGER IF NOT EXISTS attachments_thoughts_ad AFTER DELETE ON thoughts BEGIN
+    DELETE FROM attachments WHERE thought_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS blobs_attachments_ad AFTER DELETE ON attachments BEGIN
+    DELETE FROM blobs WHERE hash = old.hash AND NOT EXISTS (SELECT 1 FROM attachments WHERE hash = old.hash);
+END;
+
+CREATE TRIGGER IF NOT EXISTS blobs_attachments_au AFTER UPDATE OF hash ON attachments BEGIN
+    DELETE FROM blobs WHERE hash = old.hash AND NOT EXISTS (SELECT 1 FROM attachments WHERE hash = old.hash);
+END;
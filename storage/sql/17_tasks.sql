@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS tasks (
+    thought_id CHAR(16) NOT NULL,
+    line INTEGER NOT NULL,
+    text TEXT NOT NULL,
+    done BOOLEAN NOT NULL DEFAULT 0,
+    due VARCHAR(10) NOT NULL DEFAULT '',
+    PRIMARY KEY (thought_id, line)
+);
+
+CREATE INDEX IF NOT EXISTS tasks_done_due ON tasks (done, due);
+
+CREATE TRIGGER IF NOT EXISTS tasks_thoughts_ad AFTER DELETE ON thoughts BEGIN
+    DELETE FROM tasks WHERE thought_id = old.id;
+END;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS reminders (
+    id CHAR(16) PRIMARY KEY,
+    created DATE NOT NULL,
+    due DATE NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    target_id CHAR(16) NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    sent DATE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS reminders_status_due ON reminders (status, due);
+CREATE INDEX IF NOT EXISTS reminders_target ON reminders (type, target_id);
+
+CREATE TRIGGER IF NOT EXISTS reminders_bookmarks_ad AFTER DELETE ON bookmarks BEGIN
+    DELETE FROM reminders WHERE type = 'bookmark' AND target_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS reminders_thoughts_ad AFTER DELETE ON thoughts BEGIN
+    DELETE FROM reminders WHERE type = 'thought' AND target_id = old.id;
+END;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created DATE NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    target_id CHAR(16) NOT NULL DEFAULT '',
+    tags JSON NOT NULL DEFAULT '[]'
+);
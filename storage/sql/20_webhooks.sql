@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+    id CHAR(16) PRIMARY KEY,
+    created DATE NOT NULL,
+    updated DATE NOT NULL,
+    url TEXT NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    events JSON NOT NULL DEFAULT '[]',
+    tags JSON NOT NULL DEFAULT '[]',
+    active BOOLEAN NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id CHAR(16) NOT NULL,
+    event_id INTEGER NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    created DATE NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    next_attempt DATE NOT NULL,
+    delivered DATE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_status_next_attempt ON webhook_deliveries (status, next_attempt);
+
+CREATE TRIGGER IF NOT EXISTS webhook_deliveries_webhooks_ad AFTER DELETE ON webhooks BEGIN
+    DELETE FROM webhook_deliveries WHERE webhook_id = old.id;
+END;
@@ -0,0 +1,2 @@
+ALTER TABLE feeds ADD COLUMN hub_mode VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN hub_requested DATE NOT NULL DEFAULT '0001-01-01 00:00:00 +0000 UTC';
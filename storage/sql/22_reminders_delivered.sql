@@ -0,0 +1 @@
+ALTER TABLE reminders ADD COLUMN delivered JSON NOT NULL DEFAULT '[]';
@@ -3,9 +3,12 @@ package storage
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nrocco/qb"
 
@@ -13,6 +16,11 @@ import (
 	_ "modernc.org/sqlite"
 )
 
+var (
+	// ErrVersionConflict is returned when persisting a Thought or Bookmark that was changed since it was loaded
+	ErrVersionConflict = errors.New("Version conflict")
+)
+
 const (
 	defaultUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_1) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.0.1 Safari/605.1.15"
 )
@@ -29,7 +37,15 @@ func New(ctx context.Context, path string) (*Store, error) {
 		return &Store{}, err
 	}
 
-	store := Store{db}
+	store := Store{
+		ImageMaxSize:      5 * 1024 * 1024,
+		ImageMaxDimension: 640,
+		ImageMaxAge:       30 * 24 * time.Hour,
+		RevisionLimit:     50,
+		AttachmentMaxSize: 25 * 1024 * 1024,
+		AttachmentQuota:   1024 * 1024 * 1024,
+		db:                db,
+	}
 
 	if err := store.migrate(ctx); err != nil {
 		return &Store{}, err
@@ -40,7 +56,68 @@ func New(ctx context.Context, path string) (*Store, error) {
 
 // Store is used to persist Bookmark, Feed and Thought's
 type Store struct {
-	db *qb.DB
+	// Secret is used to encrypt sensitive values, such as feed credentials, in
+	// the database
+	Secret string
+
+	// CallbackURL is the public url of the api, used by WebSub hubs to push
+	// feed updates. Subscribing to hubs is disabled when it is empty.
+	CallbackURL string
+
+	// ImageMaxSize is the maximum size in bytes of images downloaded to the
+	// image cache, ImageMaxDimension the maximum width or height they are
+	// scaled down to and ImageMaxAge how long unused images are kept.
+	ImageMaxSize      int64
+	ImageMaxDimension int
+	ImageMaxAge       time.Duration
+
+	// RevisionLimit is the number of revisions kept per thought, 0 keeps all revisions
+	RevisionLimit int
+
+	// JournalTemplate is the text/template used for new journal thoughts,
+	// the date of the journal is available as {{ .Date }}
+	JournalTemplate string
+
+	// AttachmentMaxSize is the maximum size in bytes of a single attachment and
+	// AttachmentQuota the maximum total size of all attachments, 0 is unlimited
+	AttachmentMaxSize int64
+	AttachmentQuota   int64
+
+	db       *qb.DB
+	fetching sync.Map
+	events   eventBus
+
+	// journal serializes finding and creating the journal thought of a day
+	journal sync.Mutex
+}
+
+// updateVersioned executes an update of a versioned row which only succeeds if
+// the row still has the given version, unless replace is set in which case
+// whatever version is stored is overwritten
+func (store *Store) updateVersioned(ctx context.Context, query *qb.UpdateQuery, table, id string, version *int, replace bool) error {
+	if replace {
+		if err := store.db.Select(ctx).From(table).Columns("version").Where("id = ?", id).LoadValue(version); err != nil {
+			return err
+		}
+	}
+
+	query.Set("version", *version+1)
+	query.Where("id = ? AND version = ?", id, *version)
+
+	result, err := query.Exec()
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrVersionConflict
+	}
+
+	*version++
+
+	return nil
 }
 
 func generateUUID() (uuid string) {
@@ -41,3 +41,58 @@ func TestNewCannotCreateDatabase(t *testing.T) {
 		t.Fatal("This should have failed, but it did not")
 	}
 }
+
+func TestVersionConflict(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thought := Thought{Content: "first"}
+	if err := store.ThoughtPersist(ctx, &thought); err != nil || thought.Version != 1 {
+		t.Fatalf("Expected version 1 but got %d: %v", thought.Version, err)
+	}
+
+	stale := thought
+
+	thought.Content = "second"
+	if err := store.ThoughtPersist(ctx, &thought); err != nil || thought.Version != 2 {
+		t.Fatalf("Expected version 2 but got %d: %v", thought.Version, err)
+	}
+
+	stale.Content = "conflict"
+	if err := store.ThoughtPersist(ctx, &stale); err != ErrVersionConflict {
+		t.Fatalf("Expected ErrVersionConflict but got %v", err)
+	}
+
+	// A version of 0 does not skip the check
+	stale.Version = 0
+	if err := store.ThoughtPersist(ctx, &stale); err != ErrVersionConflict {
+		t.Fatalf("Expected ErrVersionConflict for version 0 but got %v", err)
+	}
+
+	bookmark := Bookmark{URL: "https://example.com"}
+	if err := store.BookmarkPersist(ctx, &bookmark); err != nil {
+		t.Fatal(err)
+	}
+
+	bookmark.Version = 5
+	if err := store.BookmarkPersist(ctx, &bookmark); err != ErrVersionConflict {
+		t.Fatalf("Expected ErrVersionConflict but got %v", err)
+	}
+
+	// Saving a bookmark by url replaces the stored version
+	again := Bookmark{URL: "https://example.com"}
+	if err := store.BookmarkPersist(ctx, &again); err != nil || again.ID != bookmark.ID || again.Version != 2 {
+		t.Fatalf("Expected the bookmark to be updated to version 2 but got %d: %v", again.Version, err)
+	}
+}
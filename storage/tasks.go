@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrNoTaskKey is returned if the Task does not have a ThoughtID or Line
+	ErrNoTaskKey = errors.New("Missing Task.ThoughtID or Task.Line")
+
+	// ErrTaskChanged is returned if the line of the thought is no longer the task
+	ErrTaskChanged = errors.New("Task no longer matches the thought")
+
+	taskPattern = regexp.MustCompile(`^(\s*[-*+] \[)([ xX])(\] )(.*)$`)
+	duePattern  = regexp.MustCompile(`(?:\bdue:\s*|@due\(|📅\s*)(\d{4}-\d{2}-\d{2})`)
+)
+
+// Task is a "- [ ]" checklist item in the content of a thought
+type Task struct {
+	ThoughtID string
+	Line      int
+	Text      string
+	Done      bool
+	Due       string `json:",omitempty"`
+
+	// Title is the title of the thought the task originates from
+	Title string `db:"-"`
+}
+
+// TaskListOptions can be passed to TaskList to filter tasks
+type TaskListOptions struct {
+	ThoughtID string
+	All       bool
+	Done      bool
+	DueBefore string
+	Limit     int
+	Offset    int
+}
+
+// Tasks parses the checklist items in the content of the thought, items in
+// fenced code blocks are skipped. Lines are numbered from 1.
+func (thought *Thought) Tasks() []*Task {
+	tasks := []*Task{}
+	fenced := false
+
+	for i, line := range strings.Split(thought.Content, "\n") {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+			continue
+		}
+
+		match := taskPattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if fenced || match == nil {
+			continue
+		}
+
+		task := Task{
+			ThoughtID: thought.ID,
+			Line:      i + 1,
+			Text:      strings.TrimSpace(match[4]),
+			Done:      match[2] != " ",
+		}
+
+		if due := duePattern.FindStringSubmatch(task.Text); due != nil {
+			if _, err := time.Parse(journalDateFormat, due[1]); err == nil {
+				task.Due = due[1]
+			}
+		}
+
+		tasks = append(tasks, &task)
+	}
+
+	return tasks
+}
+
+// tasksPersist replaces the tasks of the thought in the index
+func (store *Store) tasksPersist(ctx context.Context, thought *Thought) error {
+	if _, err := store.db.Delete(ctx).From("tasks").Where("thought_id = ?", thought.ID).Exec(); err != nil {
+		return err
+	}
+
+	for _, task := range thought.Tasks() {
+		query := store.db.Insert(ctx).InTo("tasks")
+		query.Columns("thought_id", "line", "text", "done", "due")
+		query.Record(task)
+
+		if _, err := query.Exec(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tasksReindex rebuilds the task index of all thoughts
+func (store *Store) tasksReindex(ctx context.Context) error {
+	thoughts := []*Thought{}
+	if _, err := store.db.Select(ctx).From("thoughts").Columns("id", "content").Load(&thoughts); err != nil {
+		return err
+	}
+
+	for _, thought := range thoughts {
+		if err := store.tasksPersist(ctx, thought); err != nil {
+			return err
+		}
+	}
+
+	log.Ctx(ctx).Info().Int("thoughts", len(thoughts)).Msg("Reindexed tasks of thoughts")
+
+	return nil
+}
+
+// TaskList lists the open tasks of all thoughts, the ones with the earliest due date first
+func (store *Store) TaskList(ctx context.Context, options *TaskListOptions) (*[]*Task, int) {
+	query := store.db.Select(ctx).From("tasks")
+	query.Join("JOIN thoughts ON thoughts.id = tasks.thought_id")
+
+	if options.ThoughtID != "" {
+		query.Where("thought_id = ?", options.ThoughtID)
+	}
+
+	if !options.All {
+		query.Where("done = ?", options.Done)
+	}
+
+	if options.DueBefore != "" {
+		query.Where("due != '' AND due <= ?", options.DueBefore)
+	}
+
+	tasks := []*Task{}
+	rows := []*struct {
+		Task
+		Content string
+	}{}
+	totalCount := 0
+
+	query.Columns("COUNT(*)")
+	if err := query.LoadValue(&totalCount); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching task count")
+		return &tasks, 0
+	}
+
+	query.Columns("thought_id", "line", "text", "done", "due", "content")
+	query.OrderBy("due = ''", "ASC").OrderBy("due", "ASC").OrderBy("thought_id", "ASC").OrderBy("line", "ASC")
+	query.Limit(options.Limit)
+	query.Offset(options.Offset)
+	if _, err := query.Load(&rows); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching tasks")
+		return &tasks, 0
+	}
+
+	for _, row := range rows {
+		thought := Thought{ID: row.ThoughtID, Content: row.Content}
+		row.Task.Title = thought.Title()
+		tasks = append(tasks, &row.Task)
+	}
+
+	return &tasks, totalCount
+}
+
+// TaskGet gets a single task of a thought
+func (store *Store) TaskGet(ctx context.Context, task *Task) error {
+	if task.ThoughtID == "" || task.Line == 0 {
+		return ErrNoTaskKey
+	}
+
+	query := store.db.Select(ctx).From("tasks")
+	query.Columns("thought_id", "line", "text", "done", "due")
+	query.Where("thought_id = ? AND line = ?", task.ThoughtID, task.Line)
+	query.Limit(1)
+
+	return query.LoadValue(&task)
+}
+
+// TaskToggle checks or unchecks the task by rewriting its line in the thought
+func (store *Store) TaskToggle(ctx context.Context, task *Task) error {
+	thought := Thought{ID: task.ThoughtID}
+	if err := store.ThoughtGet(ctx, &thought); err != nil {
+		return err
+	}
+
+	lines := strings.Split(thought.Content, "\n")
+	if task.Line < 1 || task.Line > len(lines) {
+		return ErrTaskChanged
+	}
+
+	match := taskPattern.FindStringSubmatch(lines[task.Line-1])
+	if match == nil || strings.TrimSpace(match[4]) != task.Text {
+		return ErrTaskChanged
+	}
+
+	check := "x"
+	if match[2] != " " {
+		check = " "
+	}
+
+	lines[task.Line-1] = match[1] + check + match[3] + match[4]
+	thought.Content = strings.Join(lines, "\n")
+
+	if err := store.ThoughtPersist(ctx, &thought); err != nil {
+		return err
+	}
+
+	task.Done = check == "x"
+	task.Title = thought.Title()
+
+	log.Ctx(ctx).Info().Str("id", thought.ID).Int("line", task.Line).Bool("done", task.Done).Msg("Toggled task")
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestThoughtTasksParsing(t *testing.T) {
+	thought := Thought{ID: "1", Content: "# Todo\n- [ ] buy milk due:2026-10-20\n  * [x] call mom\n```\n- [ ] not a task\n```\n- [ ] fix bike 📅 2026-13-01\n+ [X] read @due(2026-10-01)\n-[ ] broken"}
+
+	expected := []Task{
+		{ThoughtID: "1", Line: 2, Text: "buy milk due:2026-10-20", Due: "2026-10-20"},
+		{ThoughtID: "1", Line: 3, Text: "call mom", Done: true},
+		{ThoughtID: "1", Line: 7, Text: "fix bike 📅 2026-13-01"},
+		{ThoughtID: "1", Line: 8, Text: "read @due(2026-10-01)", Done: true, Due: "2026-10-01"},
+	}
+
+	tasks := thought.Tasks()
+
+	if len(tasks) != len(expected) {
+		t.Fatalf("Expected %d tasks but got %d", len(expected), len(tasks))
+	}
+
+	for i, task := range tasks {
+		if *task != expected[i] {
+			t.Fatalf("Expected %+v but got %+v", expected[i], task)
+		}
+	}
+}
+
+func TestTasks(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := Thought{Content: "# Groceries\n- [ ] milk\n- [x] eggs"}
+	second := Thought{Content: "# Work\n- [ ] report due:2026-10-20\n- [ ] taxes due:2026-10-01"}
+	for _, thought := range []*Thought{&first, &second} {
+		if err := store.ThoughtPersist(ctx, thought); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tasks, total := store.TaskList(ctx, &TaskListOptions{Limit: -1})
+	if total != 3 || (*tasks)[0].Text != "taxes due:2026-10-01" || (*tasks)[1].Line != 2 || (*tasks)[2].Text != "milk" || (*tasks)[2].Title != "Groceries" {
+		t.Fatalf("Unexpected open tasks %+v", *tasks)
+	}
+
+	if _, total := store.TaskList(ctx, &TaskListOptions{DueBefore: "2026-10-15", Limit: -1}); total != 1 {
+		t.Fatalf("Expected 1 task due before 2026-10-15 but got %d", total)
+	}
+
+	task := Task{ThoughtID: first.ID, Line: 2}
+	if err := store.TaskGet(ctx, &task); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.TaskToggle(ctx, &task); err != nil || !task.Done {
+		t.Fatalf("Expected the task to be done: %v", err)
+	}
+
+	store.ThoughtGet(ctx, &first)
+	if !strings.Contains(first.Content, "- [x] milk") {
+		t.Fatalf("Expected the thought to be rewritten but got %q", first.Content)
+	}
+
+	if _, total := store.TaskList(ctx, &TaskListOptions{Done: true, Limit: -1}); total != 2 {
+		t.Fatalf("Expected 2 done tasks but got %d", total)
+	}
+
+	stale := Task{ThoughtID: first.ID, Line: 3, Text: "bread"}
+	if err := store.TaskToggle(ctx, &stale); err != ErrTaskChanged {
+		t.Fatalf("Expected ErrTaskChanged but got %v", err)
+	}
+
+	if err := store.ThoughtDelete(ctx, &second); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, total := store.TaskList(ctx, &TaskListOptions{All: true, Limit: -1}); total != 2 {
+		t.Fatalf("Expected the tasks of the deleted thought to be removed but got %d tasks", total)
+	}
+}
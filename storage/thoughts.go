@@ -16,18 +16,50 @@ type Thought struct {
 	Updated time.Time
 	Content string
 	Tags    Tags
+	Version int
+}
+
+// Title returns the first line of the content of the thought without markdown heading markers
+func (thought *Thought) Title() string {
+	for _, line := range strings.Split(thought.Content, "\n") {
+		if line = strings.TrimSpace(strings.TrimLeft(line, "#")); line != "" {
+			return line
+		}
+	}
+
+	return thought.ID
 }
 
 // ThoughtListOptions can be passed to ThoughtList to filter thoughts
 type ThoughtListOptions struct {
-	Search string
-	Tags   Tags
-	Limit  int
-	Offset int
+	Search        string
+	Tags          Tags
+	CreatedSince  time.Time
+	CreatedBefore time.Time
+	Limit         int
+	Offset        int
 }
 
 // ThoughtList lists thoughts from the database
 func (store *Store) ThoughtList(ctx context.Context, options *ThoughtListOptions) (*[]*Thought, int) {
+	thoughts, totalCount, err := store.thoughtList(ctx, options)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching thoughts")
+		return &[]*Thought{}, 0
+	}
+
+	return thoughts, totalCount
+}
+
+// ThoughtListAll lists all thoughts from the database, unlike ThoughtList it
+// fails instead of returning no thoughts if the database can not be read
+func (store *Store) ThoughtListAll(ctx context.Context) (*[]*Thought, error) {
+	thoughts, _, err := store.thoughtList(ctx, &ThoughtListOptions{Limit: -1})
+
+	return thoughts, err
+}
+
+func (store *Store) thoughtList(ctx context.Context, options *ThoughtListOptions) (*[]*Thought, int, error) {
 	query := store.db.Select(ctx).From("thoughts")
 
 	if options.Search != "" {
@@ -44,25 +76,31 @@ func (store *Store) ThoughtList(ctx context.Context, options *ThoughtListOptions
 		}
 	}
 
+	if !options.CreatedSince.IsZero() {
+		query.Where("created >= ?", options.CreatedSince)
+	}
+
+	if !options.CreatedBefore.IsZero() {
+		query.Where("created < ?", options.CreatedBefore)
+	}
+
 	thoughts := []*Thought{}
 	totalCount := 0
 
 	query.Columns("COUNT(id)")
 	if err := query.LoadValue(&totalCount); err != nil {
-		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching thought count")
-		return &thoughts, 0
+		return nil, 0, err
 	}
 
-	query.Columns("id", "created", "updated", "content", "tags")
+	query.Columns("id", "created", "updated", "content", "tags", "version")
 	query.OrderBy("created", "DESC")
 	query.Limit(options.Limit)
 	query.Offset(options.Offset)
 	if _, err := query.Load(&thoughts); err != nil {
-		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching thoughts")
-		return &thoughts, 0
+		return nil, 0, err
 	}
 
-	return &thoughts, totalCount
+	return &thoughts, totalCount, nil
 }
 
 // ThoughtGet gets a single thought from the database
@@ -95,11 +133,15 @@ func (store *Store) ThoughtPersist(ctx context.Context, thought *Thought) error
 
 	thought.Updated = time.Now()
 
+	event := EventThoughtUpdated
+
 	if thought.ID == "" {
+		event = EventThoughtCreated
 		thought.ID = generateUUID()
+		thought.Version = 1
 
 		query := store.db.Insert(ctx).InTo("thoughts")
-		query.Columns("id", "created", "content", "tags", "updated")
+		query.Columns("id", "created", "content", "tags", "updated", "version")
 		query.Record(thought)
 
 		if _, err := query.Exec(); err != nil {
@@ -111,14 +153,27 @@ func (store *Store) ThoughtPersist(ctx context.Context, thought *Thought) error
 		query.Set("content", thought.Content)
 		query.Set("tags", thought.Tags)
 		query.Set("updated", thought.Updated)
-		query.Where("id = ?", thought.ID)
 
-		if _, err := query.Exec(); err != nil {
-			log.Ctx(ctx).Error().Err(err).Str("id", thought.ID).Msg("Error updating thought")
+		if err := store.updateVersioned(ctx, query, "thoughts", thought.ID, &thought.Version, false); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("id", thought.ID).Int("version", thought.Version).Msg("Error updating thought")
 			return err
 		}
 	}
 
+	if err := store.revisionPersist(ctx, thought); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("id", thought.ID).Msg("Error storing revision of thought")
+	}
+
+	if err := store.linksPersist(ctx, thought); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("id", thought.ID).Msg("Error indexing links of thought")
+	}
+
+	if err := store.tasksPersist(ctx, thought); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("id", thought.ID).Msg("Error indexing tasks of thought")
+	}
+
+	store.emit(ctx, event, thought.ID, thought.Tags)
+
 	log.Ctx(ctx).Info().Str("id", thought.ID).Msg("Persisted thought")
 
 	return nil
@@ -141,6 +196,8 @@ func (store *Store) ThoughtDelete(ctx context.Context, thought *Thought) error {
 		return err
 	}
 
+	store.emit(ctx, EventThoughtDeleted, thought.ID, thought.Tags)
+
 	log.Ctx(ctx).Info().Str("id", thought.ID).Msg("Thought refreshed")
 
 	return nil
@@ -0,0 +1,444 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// WebhookDeliveryPending is the status of a delivery that has not succeeded yet
+	WebhookDeliveryPending = "pending"
+
+	// WebhookDeliveryDelivered is the status of a delivery that was accepted by the webhook
+	WebhookDeliveryDelivered = "delivered"
+
+	// WebhookDeliveryFailed is the status of a delivery that failed WebhookMaxAttempts times
+	WebhookDeliveryFailed = "failed"
+
+	// WebhookMaxAttempts is the number of times a delivery is tried
+	WebhookMaxAttempts = 6
+
+	// webhookHistory is how long finished deliveries are kept in the delivery log
+	webhookHistory = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrNoWebhookKey is returned if the Webhook does not have an ID
+	ErrNoWebhookKey = errors.New("Missing Webhook.ID")
+
+	// ErrInvalidWebhook is returned if the Webhook does not have a http or https URL
+	ErrInvalidWebhook = errors.New("Webhook needs a http or https URL")
+
+	// ErrWebhookInactive is returned when delivering to a Webhook that is not active
+	ErrWebhookInactive = errors.New("Webhook is not active")
+
+	// webhookBackoff is the time to wait before the next attempt after each failed attempt
+	webhookBackoff = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 12 * time.Hour}
+
+	// webhookClient posts deliveries, webhooks are not crawled so they do not
+	// go through the DefaultFetcher with its user agent, politeness and statistics
+	webhookClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// Webhook posts the events that match its event types and tags to an url
+type Webhook struct {
+	ID      string
+	Created time.Time
+	Updated time.Time
+	URL     string
+	Secret  string
+	Events  Tags
+	Tags    Tags
+	Active  bool
+}
+
+// WebhookDelivery is an event that is posted to a webhook
+type WebhookDelivery struct {
+	ID          int64
+	WebhookID   string
+	EventID     int64
+	EventType   string
+	Created     time.Time
+	Payload     string `json:"-"`
+	Status      string
+	Attempts    int
+	StatusCode  int
+	Error       string `json:",omitempty"`
+	NextAttempt time.Time
+	Delivered   time.Time
+}
+
+// WebhookDeliveryListOptions can be passed to WebhookDeliveryList to filter deliveries
+type WebhookDeliveryListOptions struct {
+	Status string
+	Limit  int
+	Offset int
+}
+
+// webhookPayload is the json body posted to a webhook, Data holds the
+// bookmark, feed or thought at the time of the event
+type webhookPayload struct {
+	ID       int64
+	Created  time.Time
+	Type     string
+	TargetID string
+	Tags     Tags
+	Data     interface{} `json:",omitempty"`
+}
+
+// Matches returns true if the webhook is active and the event has one of its
+// event types and one of its tags. A webhook without event types or tags
+// matches all events.
+func (webhook *Webhook) Matches(event *Event) bool {
+	if !webhook.Active || !event.Matches(webhook.Events) {
+		return false
+	}
+
+	if len(webhook.Tags) == 0 {
+		return true
+	}
+
+	for _, tag := range webhook.Tags {
+		for _, eventTag := range event.Tags {
+			if tag == eventTag {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Sign returns the X-Bookmarks-Signature header of the body, a hex encoded
+// hmac-sha256 of the body using the secret of the webhook
+func (webhook *Webhook) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookList lists all webhooks, the oldest first
+func (store *Store) WebhookList(ctx context.Context) *[]*Webhook {
+	webhooks := []*Webhook{}
+
+	query := store.db.Select(ctx).From("webhooks")
+	query.OrderBy("created", "ASC")
+
+	if _, err := query.Load(&webhooks); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching webhooks")
+	}
+
+	return &webhooks
+}
+
+// WebhookGet gets a single webhook from the database
+func (store *Store) WebhookGet(ctx context.Context, webhook *Webhook) error {
+	if webhook.ID == "" {
+		return ErrNoWebhookKey
+	}
+
+	query := store.db.Select(ctx).From("webhooks")
+	query.Where("id = ?", webhook.ID)
+	query.Limit(1)
+
+	return query.LoadValue(&webhook)
+}
+
+// WebhookPersist adds a new webhook to the database or updates an existing
+// one, a secret is generated if the webhook does not have one
+func (store *Store) WebhookPersist(ctx context.Context, webhook *Webhook) error {
+	if location, err := url.Parse(webhook.URL); err != nil || (location.Scheme != "http" && location.Scheme != "https") || location.Host == "" {
+		return ErrInvalidWebhook
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret = generateUUID() + generateUUID()
+	}
+
+	if webhook.Events == nil {
+		webhook.Events = Tags{}
+	}
+
+	if webhook.Tags == nil {
+		webhook.Tags = Tags{}
+	}
+
+	webhook.Updated = time.Now()
+
+	if webhook.ID == "" {
+		webhook.ID = generateUUID()
+		webhook.Created = webhook.Updated
+
+		query := store.db.Insert(ctx).InTo("webhooks")
+		query.Columns("id", "created", "updated", "url", "secret", "events", "tags", "active")
+		query.Record(webhook)
+
+		if _, err := query.Exec(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("id", webhook.ID).Msg("Error persisting webhook")
+			return err
+		}
+	} else {
+		query := store.db.Update(ctx).Table("webhooks")
+		query.Set("updated", webhook.Updated)
+		query.Set("url", webhook.URL)
+		query.Set("secret", webhook.Secret)
+		query.Set("events", webhook.Events)
+		query.Set("tags", webhook.Tags)
+		query.Set("active", webhook.Active)
+		query.Where("id = ?", webhook.ID)
+
+		if _, err := query.Exec(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("id", webhook.ID).Msg("Error updating webhook")
+			return err
+		}
+	}
+
+	log.Ctx(ctx).Info().Str("id", webhook.ID).Str("url", webhook.URL).Msg("Persisted webhook")
+
+	return nil
+}
+
+// WebhookDelete removes a webhook and its deliveries from the database
+func (store *Store) WebhookDelete(ctx context.Context, webhook *Webhook) error {
+	if webhook.ID == "" {
+		return ErrNoWebhookKey
+	}
+
+	if _, err := store.db.Delete(ctx).From("webhooks").Where("id = ?", webhook.ID).Exec(); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("id", webhook.ID).Msg("Error deleting webhook")
+		return err
+	}
+
+	log.Ctx(ctx).Info().Str("id", webhook.ID).Msg("Deleted webhook")
+
+	return nil
+}
+
+// WebhookDeliveryList lists the deliveries of a webhook, the most recent first
+func (store *Store) WebhookDeliveryList(ctx context.Context, webhook *Webhook, options *WebhookDeliveryListOptions) (*[]*WebhookDelivery, int) {
+	query := store.db.Select(ctx).From("webhook_deliveries")
+	query.Where("webhook_id = ?", webhook.ID)
+
+	if options.Status != "" {
+		query.Where("status = ?", options.Status)
+	}
+
+	deliveries := []*WebhookDelivery{}
+	totalCount := 0
+
+	query.Columns("COUNT(id)")
+	if err := query.LoadValue(&totalCount); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching webhook delivery count")
+		return &deliveries, 0
+	}
+
+	query.Columns("*")
+	query.OrderBy("id", "DESC")
+	query.Limit(options.Limit)
+	query.Offset(options.Offset)
+	if _, err := query.Load(&deliveries); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching webhook deliveries")
+		return &deliveries, 0
+	}
+
+	return &deliveries, totalCount
+}
+
+// WebhookEnqueue adds a pending delivery of the event for every webhook that
+// matches it and returns the number of deliveries
+func (store *Store) WebhookEnqueue(ctx context.Context, event *Event) int {
+	var payload []byte
+
+	count := 0
+	for _, webhook := range *store.WebhookList(ctx) {
+		if !webhook.Matches(event) {
+			continue
+		}
+
+		// The payload is created once so all webhooks and attempts get the same body
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(store.webhookPayload(ctx, event)); err != nil {
+				log.Ctx(ctx).Error().Err(err).Int64("event", event.ID).Msg("Error encoding webhook payload")
+				return count
+			}
+		}
+
+		delivery := WebhookDelivery{
+			WebhookID:   webhook.ID,
+			EventID:     event.ID,
+			EventType:   event.Type,
+			Created:     time.Now(),
+			Payload:     string(payload),
+			Status:      WebhookDeliveryPending,
+			NextAttempt: time.Now().UTC().Truncate(time.Second),
+		}
+
+		query := store.db.Insert(ctx).InTo("webhook_deliveries")
+		query.Columns("webhook_id", "event_id", "event_type", "created", "payload", "status", "attempts", "status_code", "error", "next_attempt", "delivered")
+		query.Record(&delivery)
+
+		if _, err := query.Exec(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("webhook", webhook.ID).Int64("event", event.ID).Msg("Error persisting webhook delivery")
+			continue
+		}
+
+		count++
+	}
+
+	if event.ID%100 == 0 {
+		query := store.db.Delete(ctx).From("webhook_deliveries")
+		query.Where("status != ? AND created < ?", WebhookDeliveryPending, time.Now().Add(-webhookHistory))
+		query.Exec()
+	}
+
+	return count
+}
+
+// WebhookDue returns the pending deliveries of which the next attempt is due at the given time
+func (store *Store) WebhookDue(ctx context.Context, now time.Time) []*WebhookDelivery {
+	deliveries := []*WebhookDelivery{}
+
+	query := store.db.Select(ctx).From("webhook_deliveries")
+	query.Where("status = ? AND next_attempt <= ?", WebhookDeliveryPending, now.UTC())
+	query.OrderBy("id", "ASC")
+	query.Limit(100)
+
+	if _, err := query.Load(&deliveries); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Error fetching due webhook deliveries")
+	}
+
+	return deliveries
+}
+
+// WebhookDeliver posts the payload of the delivery to its webhook and records
+// the result. A failed delivery is tried again with an increasing delay until
+// it failed WebhookMaxAttempts times.
+func (store *Store) WebhookDeliver(ctx context.Context, delivery *WebhookDelivery) error {
+	webhook := Webhook{ID: delivery.WebhookID}
+	if err := store.WebhookGet(ctx, &webhook); err != nil {
+		return err
+	}
+
+	var err error
+	var retryAfter time.Duration
+
+	delivery.Attempts++
+
+	if webhook.Active {
+		delivery.StatusCode, retryAfter, err = store.webhookPost(ctx, &webhook, delivery)
+	} else {
+		err = ErrWebhookInactive
+		delivery.Attempts = WebhookMaxAttempts
+	}
+
+	if err == nil {
+		delivery.Status = WebhookDeliveryDelivered
+		delivery.Error = ""
+		delivery.Delivered = time.Now()
+	} else if delivery.Attempts >= WebhookMaxAttempts {
+		delivery.Status = WebhookDeliveryFailed
+		delivery.Error = err.Error()
+	} else {
+		delay := webhookBackoff[delivery.Attempts-1]
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+
+		delivery.Error = err.Error()
+		delivery.NextAttempt = time.Now().Add(delay).UTC().Truncate(time.Second)
+	}
+
+	query := store.db.Update(ctx).Table("webhook_deliveries")
+	query.Set("status", delivery.Status)
+	query.Set("attempts", delivery.Attempts)
+	query.Set("status_code", delivery.StatusCode)
+	query.Set("error", delivery.Error)
+	query.Set("next_attempt", delivery.NextAttempt)
+	query.Set("delivered", delivery.Delivered)
+	query.Where("id = ?", delivery.ID)
+
+	if _, err := query.Exec(); err != nil {
+		log.Ctx(ctx).Error().Err(err).Int64("id", delivery.ID).Msg("Error updating webhook delivery")
+		return err
+	}
+
+	return err
+}
+
+// webhookPost posts the signed payload of the delivery to the webhook and
+// returns the status code of the response and how long to wait before the
+// next attempt as requested by the webhook
+func (store *Store) webhookPost(ctx context.Context, webhook *Webhook, delivery *WebhookDelivery) (int, time.Duration, error) {
+	body := []byte(delivery.Payload)
+
+	request, err := http.NewRequestWithContext(ctx, "POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "bookmarks-webhook")
+	request.Header.Set("X-Bookmarks-Event", delivery.EventType)
+	request.Header.Set("X-Bookmarks-Delivery", strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set("X-Bookmarks-Signature", webhook.Sign(body))
+
+	response, err := webhookClient.Do(request)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		httpErr := newHTTPError(response)
+		return httpErr.StatusCode, httpErr.RetryAfter, httpErr
+	}
+
+	return response.StatusCode, 0, nil
+}
+
+// webhookPayload returns the payload of the event, including the bookmark,
+// feed or thought it is about if it still exists
+func (store *Store) webhookPayload(ctx context.Context, event *Event) *webhookPayload {
+	payload := webhookPayload{
+		ID:       event.ID,
+		Created:  event.Created,
+		Type:     event.Type,
+		TargetID: event.TargetID,
+		Tags:     event.Tags,
+	}
+
+	switch strings.SplitN(event.Type, ".", 2)[0] {
+	case "bookmark":
+		bookmark := Bookmark{ID: event.TargetID}
+		if store.BookmarkGet(ctx, &bookmark) == nil {
+			payload.Data = &bookmark
+		}
+	case "feed":
+		feed := Feed{ID: event.TargetID}
+		if store.FeedGet(ctx, &feed) == nil {
+			payload.Data = &feed
+		}
+	case "thought":
+		thought := Thought{ID: event.TargetID}
+		if store.ThoughtGet(ctx, &thought) == nil {
+			payload.Data = &thought
+		}
+	}
+
+	return &payload
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookMatches(t *testing.T) {
+	tests := []struct {
+		webhook  Webhook
+		event    Event
+		expected bool
+	}{
+		{Webhook{Active: true}, Event{Type: EventBookmarkCreated}, true},
+		{Webhook{Active: false}, Event{Type: EventBookmarkCreated}, false},
+		{Webhook{Active: true, Events: Tags{"bookmark"}}, Event{Type: EventBookmarkCreated}, true},
+		{Webhook{Active: true, Events: Tags{"bookmark.created"}}, Event{Type: EventBookmarkDeleted}, false},
+		{Webhook{Active: true, Events: Tags{"book"}}, Event{Type: EventBookmarkCreated}, false},
+		{Webhook{Active: true, Tags: Tags{"ci"}}, Event{Type: EventThoughtUpdated, Tags: Tags{"work", "ci"}}, true},
+		{Webhook{Active: true, Tags: Tags{"ci"}}, Event{Type: EventThoughtUpdated, Tags: Tags{"work"}}, false},
+		{Webhook{Active: true, Events: Tags{"feed"}, Tags: Tags{"news"}}, Event{Type: EventThoughtUpdated, Tags: Tags{"news"}}, false},
+	}
+
+	for _, test := range tests {
+		if actual := test.webhook.Matches(&test.event); actual != test.expected {
+			t.Fatalf("Expected %v for %s %v with webhook %v %v but got %v", test.expected, test.event.Type, test.event.Tags, test.webhook.Events, test.webhook.Tags, actual)
+		}
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := 500
+	requests := []*http.Request{}
+	bodies := [][]byte{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	if err := store.WebhookPersist(ctx, &Webhook{URL: "ftp://example.com"}); err != ErrInvalidWebhook {
+		t.Fatalf("Expected ErrInvalidWebhook but got %v", err)
+	}
+
+	webhook := Webhook{URL: server.URL, Events: Tags{"thought"}, Tags: Tags{"ci"}, Active: true}
+	if err := store.WebhookPersist(ctx, &webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	if webhook.Secret == "" {
+		t.Fatal("Expected a generated secret")
+	}
+
+	thought := Thought{Content: "# Release notes", Tags: Tags{"ci"}}
+	if err := store.ThoughtPersist(ctx, &thought); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.BookmarkPersist(ctx, &Bookmark{URL: "https://example.com/", Title: "Example", Tags: Tags{"ci"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := store.EventList(ctx, 0, -1)
+
+	enqueued := 0
+	for _, event := range events {
+		enqueued += store.WebhookEnqueue(ctx, event)
+	}
+
+	if enqueued != 1 {
+		t.Fatalf("Expected 1 delivery but got %d", enqueued)
+	}
+
+	due := store.WebhookDue(ctx, time.Now())
+	if len(due) != 1 {
+		t.Fatalf("Expected 1 due delivery but got %d", len(due))
+	}
+
+	if err := store.WebhookDeliver(ctx, due[0]); err == nil {
+		t.Fatal("Expected the delivery to fail")
+	}
+
+	deliveries, _ := store.WebhookDeliveryList(ctx, &webhook, &WebhookDeliveryListOptions{Limit: 10})
+	if delivery := (*deliveries)[0]; delivery.Status != WebhookDeliveryPending || delivery.Attempts != 1 || delivery.StatusCode != 500 || delivery.Error == "" {
+		t.Fatalf("Expected a pending delivery after the first failed attempt but got %v", delivery)
+	}
+
+	if due := store.WebhookDue(ctx, time.Now()); len(due) != 0 {
+		t.Fatalf("Expected the next attempt to be delayed but got %d due deliveries", len(due))
+	}
+
+	status = 204
+
+	due = store.WebhookDue(ctx, time.Now().Add(time.Minute))
+	if err := store.WebhookDeliver(ctx, due[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, totalCount := store.WebhookDeliveryList(ctx, &webhook, &WebhookDeliveryListOptions{Status: WebhookDeliveryDelivered, Limit: 10})
+	if totalCount != 1 || (*deliveries)[0].Attempts != 2 || (*deliveries)[0].StatusCode != 204 || (*deliveries)[0].Delivered.IsZero() {
+		t.Fatalf("Expected a delivered delivery but got %v", *deliveries)
+	}
+
+	if len(requests) != 2 || string(bodies[0]) != string(bodies[1]) {
+		t.Fatalf("Expected the same payload to be posted twice but got %d requests", len(requests))
+	}
+
+	request := requests[1]
+	if request.Header.Get("User-Agent") != "bookmarks-webhook" {
+		t.Fatalf("Expected the webhook user agent but got %q", request.Header.Get("User-Agent"))
+	}
+
+	for _, stats := range DefaultFetcher.Stats() {
+		if strings.Contains(server.URL, stats.Host) {
+			t.Fatalf("Expected webhook requests not to be recorded in the host statistics but got %v", stats)
+		}
+	}
+
+	if request.Header.Get("X-Bookmarks-Event") != EventThoughtCreated {
+		t.Fatalf("Expected the event type header but got %q", request.Header.Get("X-Bookmarks-Event"))
+	}
+
+	if request.Header.Get("X-Bookmarks-Signature") != webhook.Sign(bodies[1]) {
+		t.Fatalf("Expected a valid signature but got %q", request.Header.Get("X-Bookmarks-Signature"))
+	}
+
+	payload := struct {
+		Type string
+		Data Thought
+	}{}
+	if err := json.Unmarshal(bodies[1], &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.Type != EventThoughtCreated || payload.Data.ID != thought.ID {
+		t.Fatalf("Expected the thought in the payload but got %v", payload)
+	}
+
+	if err := store.WebhookDelete(ctx, &webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, totalCount := store.WebhookDeliveryList(ctx, &webhook, &WebhookDeliveryListOptions{Limit: 10}); totalCount != 0 {
+		t.Fatalf("Expected the deliveries to be deleted with the webhook but got %d", totalCount)
+	}
+}
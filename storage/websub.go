@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// hubLeaseSeconds is the lease we ask the hub for, the hub might grant a different one
+	hubLeaseSeconds = 7 * 24 * 60 * 60
+
+	// hubMaxLeaseSeconds is the longest lease that is accepted, after which the feed is polled again
+	hubMaxLeaseSeconds = 30 * 24 * 60 * 60
+
+	// hubIntentTimeout is how long the hub has to verify a subscription request
+	hubIntentTimeout = time.Hour
+)
+
+var (
+	// ErrNoFeedHub is returned if the Feed does not advertise a WebSub hub
+	ErrNoFeedHub = errors.New("Missing Feed.Hub")
+
+	// ErrHubTopicMismatch is returned if the hub verifies a subscription for a different topic
+	ErrHubTopicMismatch = errors.New("Topic does not match Feed.HubTopic")
+
+	// ErrHubUnsolicited is returned if the hub verifies a mode that was not requested recently
+	ErrHubUnsolicited = errors.New("No pending request for this hub.mode")
+
+	// ErrHubInvalidSignature is returned if pushed content is not signed with Feed.HubSecret
+	ErrHubInvalidSignature = errors.New("Invalid X-Hub-Signature")
+)
+
+// discoverHub finds the WebSub hub and topic url in the Link headers or in the link elements of the feed document
+func discoverHub(header http.Header, body []byte) (hub string, topic string) {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			href := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+
+			for _, param := range parts[1:] {
+				param = strings.ReplaceAll(strings.TrimSpace(param), "\"", "")
+				if param == "rel=hub" && hub == "" {
+					hub = href
+				} else if param == "rel=self" && topic == "" {
+					topic = href
+				}
+			}
+		}
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+
+	for hub == "" || topic == "" {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if element.Name.Local == "item" || element.Name.Local == "entry" {
+			break
+		}
+
+		if element.Name.Local != "link" {
+			continue
+		}
+
+		var rel, href string
+		for _, attr := range element.Attr {
+			switch attr.Name.Local {
+			case "rel":
+				rel = attr.Value
+			case "href":
+				href = attr.Value
+			}
+		}
+
+		if rel == "hub" && hub == "" {
+			hub = href
+		} else if rel == "self" && topic == "" {
+			topic = href
+		}
+	}
+
+	return hub, topic
+}
+
+// FeedSubscribe asks the WebSub hub of the feed to push new content to Store.CallbackURL
+func (store *Store) FeedSubscribe(ctx context.Context, feed *Feed) error {
+	if feed.Hub == "" {
+		return ErrNoFeedHub
+	}
+
+	feed.HubSecret = generateUUID() + generateUUID()
+	feed.HubMode = "subscribe"
+	feed.HubRequested = time.Now()
+
+	if err := store.FeedPersist(ctx, feed); err != nil {
+		return err
+	}
+
+	form := url.Values{}
+	form.Set("hub.mode", "subscribe")
+	form.Set("hub.topic", feed.HubTopic)
+	form.Set("hub.callback", strings.TrimSuffix(store.CallbackURL, "/")+"/websub/"+feed.ID)
+	form.Set("hub.secret", feed.HubSecret)
+	form.Set("hub.lease_seconds", fmt.Sprintf("%d", hubLeaseSeconds))
+
+	request, err := http.NewRequestWithContext(ctx, "POST", feed.Hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := DefaultFetcher.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	log.Ctx(ctx).Info().Str("id", feed.ID).Str("hub", feed.Hub).Str("topic", feed.HubTopic).Msg("Requested subscription at hub")
+
+	return nil
+}
+
+// FeedVerifySubscription handles the verification of intent of the hub for
+// the given mode and topic. Only a mode that was requested by FeedSubscribe
+// within hubIntentTimeout is confirmed and the lease is at most
+// hubMaxLeaseSeconds, so nobody else can stop the feed from being polled.
+func (store *Store) FeedVerifySubscription(ctx context.Context, feed *Feed, mode, topic string, leaseSeconds int) error {
+	if feed.Hub == "" {
+		return ErrNoFeedHub
+	}
+
+	if topic != feed.HubTopic {
+		return ErrHubTopicMismatch
+	}
+
+	requested := mode
+	if mode == "denied" {
+		requested = "subscribe"
+	}
+
+	if feed.HubMode == "" || feed.HubMode != requested || time.Since(feed.HubRequested) > hubIntentTimeout {
+		return ErrHubUnsolicited
+	}
+
+	if leaseSeconds <= 0 {
+		leaseSeconds = hubLeaseSeconds
+	} else if leaseSeconds > hubMaxLeaseSeconds {
+		leaseSeconds = hubMaxLeaseSeconds
+	}
+
+	switch mode {
+	case "subscribe":
+		feed.HubExpires = time.Now().Add(time.Duration(leaseSeconds) * time.Second)
+	case "unsubscribe", "denied":
+		feed.HubExpires = time.Time{}
+	default:
+		return fmt.Errorf("Unknown hub.mode %s", mode)
+	}
+
+	feed.HubMode = ""
+	feed.HubRequested = time.Time{}
+
+	if err := store.FeedPersist(ctx, feed); err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Str("id", feed.ID).Str("mode", mode).Time("expires", feed.HubExpires).Msg("Verified hub subscription")
+
+	return nil
+}
+
+// FeedPush validates the signature of content pushed by the hub and persists the new items in the background the same way as FeedRefresh
+func (store *Store) FeedPush(ctx context.Context, feed *Feed, signature string, body []byte) error {
+	if !validHubSignature(feed.HubSecret, signature, body) {
+		return ErrHubInvalidSignature
+	}
+
+	// The hub only waits for an acknowledgement, so the items are processed after the request is done
+	go store.feedPush(log.Ctx(ctx).WithContext(context.Background()), feed, body)
+
+	return nil
+}
+
+// feedPush parses the content pushed by the hub and persists the new items of the feed
+func (store *Store) feedPush(ctx context.Context, feed *Feed, body []byte) {
+	existing := feed.itemIDs()
+
+	if err := feed.parse(ctx, body); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("id", feed.ID).Msg("Error parsing content pushed by hub")
+		return
+	}
+
+	if err := store.feedUpdate(ctx, feed, existing); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("id", feed.ID).Msg("Error persisting content pushed by hub")
+		return
+	}
+
+	log.Ctx(ctx).Info().Str("id", feed.ID).Str("url", feed.URL).Msg("Feed pushed by hub")
+}
+
+// validHubSignature checks a X-Hub-Signature header in the form of method=signature
+func validHubSignature(secret, signature string, body []byte) bool {
+	if secret == "" {
+		return false
+	}
+
+	parts := strings.SplitN(signature, "=", 2)
+	if len(parts) != 2 {
+		return false
+	}
+
+	var algorithm func() hash.Hash
+
+	switch parts[0] {
+	case "sha1":
+		algorithm = sha1.New
+	case "sha256":
+		algorithm = sha256.New
+	case "sha384":
+		algorithm = sha512.New384
+	case "sha512":
+		algorithm = sha512.New
+	default:
+		return false
+	}
+
+	expected, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(algorithm, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
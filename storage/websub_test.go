@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiscoverHub(t *testing.T) {
+	body := []byte(`<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <link rel="hub" href="https://hub.example.com/"/>
+  <link rel="self" href="https://example.com/feed.atom"/>
+  <entry><link rel="self" href="https://example.com/entry"/></entry>
+</feed>`)
+
+	hub, topic := discoverHub(http.Header{}, body)
+	if hub != "https://hub.example.com/" || topic != "https://example.com/feed.atom" {
+		t.Fatalf("Unexpected hub %s and topic %s", hub, topic)
+	}
+
+	header := http.Header{}
+	header.Add("Link", `<https://other-hub.example.com/>; rel="hub", <https://example.com/feed>; rel="self"`)
+
+	hub, topic = discoverHub(header, body)
+	if hub != "https://other-hub.example.com/" || topic != "https://example.com/feed" {
+		t.Fatalf("Expected the Link header to take precedence, got hub %s and topic %s", hub, topic)
+	}
+}
+
+func TestValidHubSignature(t *testing.T) {
+	body := []byte("hello")
+
+	signatures := map[string]bool{
+		"sha1=8a3e5e3a7e3d5b2ec1e8f0e55c5ba7ed1e96e0ec":                           false,
+		"sha256=88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b": true,
+		"sha256=xyz": false,
+		"md5=abc":    false,
+		"":           false,
+	}
+
+	for signature, expected := range signatures {
+		if validHubSignature("secret", signature, body) != expected {
+			t.Fatalf("Expected signature %q to be valid: %v", signature, expected)
+		}
+	}
+
+	if validHubSignature("", "sha256=88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b", body) {
+		t.Fatal("Expected an empty secret to never validate")
+	}
+}
+
+func TestFeedVerifySubscription(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	store, err := New(ctx, filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.CallbackURL = "https://bookmarks.example.com/"
+
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(202)
+	}))
+	defer hub.Close()
+
+	feed := Feed{URL: "https://example.com/feed.atom", Hub: hub.URL, HubTopic: "https://example.com/feed.atom"}
+	if err := store.FeedPersist(ctx, &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.FeedVerifySubscription(ctx, &feed, "subscribe", feed.HubTopic, 3600); err != ErrHubUnsolicited {
+		t.Fatalf("Expected an unsolicited subscription to be rejected but got %v", err)
+	}
+
+	if err := store.FeedSubscribe(ctx, &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.FeedVerifySubscription(ctx, &feed, "unsubscribe", feed.HubTopic, 0); err != ErrHubUnsolicited {
+		t.Fatalf("Expected an unsolicited unsubscribe to be rejected but got %v", err)
+	}
+
+	if err := store.FeedVerifySubscription(ctx, &feed, "subscribe", "https://example.com/other", 3600); err != ErrHubTopicMismatch {
+		t.Fatalf("Expected ErrHubTopicMismatch but got %v", err)
+	}
+
+	if err := store.FeedVerifySubscription(ctx, &feed, "subscribe", feed.HubTopic, 10*365*24*60*60); err != nil {
+		t.Fatal(err)
+	}
+
+	if max := time.Now().Add(hubMaxLeaseSeconds * time.Second); feed.HubExpires.After(max) {
+		t.Fatalf("Expected the lease to be at most %s but got %s", max, feed.HubExpires)
+	}
+
+	stored := Feed{ID: feed.ID}
+	if err := store.FeedGet(ctx, &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.HubMode != "" || !stored.HubExpires.Equal(feed.HubExpires) {
+		t.Fatalf("Expected the verified subscription to be stored but got mode %q and expires %s", stored.HubMode, stored.HubExpires)
+	}
+
+	if err := store.FeedVerifySubscription(ctx, &stored, "subscribe", feed.HubTopic, 3600); err != ErrHubUnsolicited {
+		t.Fatalf("Expected a second verification to be rejected but got %v", err)
+	}
+}
+
+func TestFeedPush(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	store, err := New(context.Background(), filepath.Join(tmpDir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feed := Feed{URL: "https://example.com/feed.atom", HubSecret: "secret"}
+	if err := store.FeedPersist(context.Background(), &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry><id>1</id><title>Pushed</title><link href="https://example.com/pushed"/></entry>
+</feed>`)
+
+	if err := store.FeedPush(context.Background(), &feed, "sha256=abc", body); err != ErrHubInvalidSignature {
+		t.Fatalf("Expected ErrHubInvalidSignature but got %v", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+
+	// The request is done before the pushed items are processed
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := store.FeedPush(ctx, &feed, "sha256="+hex.EncodeToString(mac.Sum(nil)), body); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	stored := Feed{ID: feed.ID}
+
+	for i := 0; i < 100; i++ {
+		if err := store.FeedGet(context.Background(), &stored); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(stored.Items) > 0 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(stored.Items) != 1 || stored.Items[0].Title != "Pushed" {
+		t.Fatalf("Expected the pushed item to be stored but got %+v", stored.Items)
+	}
+}